@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+	cmdUtils "snare.dev/optnix/internal/cmd/utils"
+	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/logger"
+	"snare.dev/optnix/option"
+)
+
+type ChangedOpts struct {
+	Scope string
+	JSON  bool
+	Jobs  int
+
+	Prefix string
+}
+
+func ChangedCommand() *cobra.Command {
+	opts := ChangedOpts{}
+
+	cmd := cobra.Command{
+		Use:   "changed -s [SCOPE] [PREFIX]",
+		Short: "List options that differ from their defaults",
+		Long:  "Evaluate all options under a prefix, and list the ones that have been changed from their default values.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{Msg: err.Error()}
+			}
+
+			if len(args) > 0 {
+				opts.Prefix = args[0]
+			}
+
+			if opts.Jobs < 1 {
+				return cmdUtils.ErrorWithHint{Msg: fmt.Sprintf("--jobs must be at least 1, got %v", opts.Jobs)}
+			}
+
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+
+			if opts.Scope == "" {
+				opts.Scope = cfg.DefaultScope
+			}
+
+			if opts.Scope == "" {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "no scope was provided and no default scope is set in the configuration",
+					Hint: "either set a default configuration or specify one with -s",
+				}
+			}
+
+			return nil
+		},
+		ValidArgsFunction: completeOptionsFromScope(&opts.Scope),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := changedMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Scope, "scope", "s", "", "Scope `name` to use")
+	cmd.Flags().BoolVarP(&opts.JSON, "json", "j", false, "Output changed options in JSON format")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "J", runtime.NumCPU(), "Number of options to evaluate in `parallel`")
+
+	_ = cmd.RegisterFlagCompletionFunc("scope", completeScopes)
+
+	return &cmd
+}
+
+type changedOption struct {
+	Name    string  `json:"name"`
+	Value   string  `json:"value"`
+	Default *string `json:"default"`
+}
+
+// Determine if an option falls under a prefix. The prefix must
+// match whole attribute names, so `services.nginx` does not match
+// `services.nginxCustom.enable`.
+func optionHasPrefix(name string, prefix string) bool {
+	if prefix == "" || name == prefix {
+		return true
+	}
+	return strings.HasPrefix(name, strings.TrimSuffix(prefix, ".")+".")
+}
+
+// Largest number of options to give to a batch evaluator at once.
+const maxEvalBatchSize = 100
+
+// Evaluate many options in a scope, using at most the given
+// number of evaluators at once. The progress function is called
+// with the number of evaluated options after each one finishes.
+//
+// If the scope has a batch evaluator, options are evaluated in
+// batches, and options in a batch that fails (i.e. because one of
+// them fails to evaluate) are evaluated one at a time instead.
+func evaluateOptions(scope *option.Scope, options []option.NixosOption, jobs int, progress func(done int)) []scopeEvalResult {
+	results := make([]scopeEvalResult, len(options))
+
+	var completed atomic.Int64
+	report := func(n int) {
+		done := completed.Add(int64(n))
+		if progress != nil {
+			progress(int(done))
+		}
+	}
+
+	// Split options evenly between evaluators, so that small sets of
+	// options are still evaluated in parallel.
+	batchSize := 1
+	if scope.BatchEvaluator != nil {
+		batchSize = min(max((len(options)+jobs-1)/jobs, 1), maxEvalBatchSize)
+	}
+
+	batches := make(chan []int)
+
+	var wg sync.WaitGroup
+	for range min(jobs, (len(options)+batchSize-1)/batchSize) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for batch := range batches {
+				if scope.BatchEvaluator != nil && evaluateBatch(scope, options, batch, results) {
+					report(len(batch))
+					continue
+				}
+
+				for _, i := range batch {
+					o := &options[i]
+					results[i].Value, results[i].Err = scope.EvaluateNix(o.Name, o.Location)
+					report(1)
+				}
+			}
+		}()
+	}
+
+	for start := 0; start < len(options); start += batchSize {
+		var batch []int
+		for i := start; i < min(start+batchSize, len(options)); i++ {
+			batch = append(batch, i)
+		}
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	return results
+}
+
+// Evaluate a batch of options with the scope's batch evaluator,
+// returning whether or not it succeeded.
+func evaluateBatch(scope *option.Scope, options []option.NixosOption, batch []int, results []scopeEvalResult) bool {
+	names := make([]string, len(batch))
+	locations := make([][]string, len(batch))
+	for j, i := range batch {
+		names[j] = options[i].Name
+		locations[j] = options[i].Location
+	}
+
+	values, err := scope.EvaluateNixBatch(names, locations)
+	if err != nil {
+		return false
+	}
+
+	for j, i := range batch {
+		results[i].Value = values[j]
+	}
+
+	return true
+}
+
+func newEvalSpinner() *pin.Pin {
+	return pin.New("Loading...",
+		pin.WithSpinnerColor(pin.ColorCyan),
+		pin.WithTextColor(pin.ColorRed),
+		pin.WithPosition(pin.PositionRight),
+		pin.WithSpinnerFrames([]rune{'-', '\\', '|', '/'}),
+		pin.WithWriter(os.Stderr),
+	)
+}
+
+// Construct a scope that can be evaluated from the configuration.
+func loadEvaluableScope(cfg *config.Config, name string) (*option.Scope, error) {
+	s, err := cfg.ResolveScope(name)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := constructScopeFromConfig(&s, constructValueFormatter(cfg))
+	if scope.Evaluator == nil {
+		return nil, fmt.Errorf("scope '%v' does not have an evaluator configured", name)
+	}
+
+	return &scope, nil
+}
+
+func changedMain(cmd *cobra.Command, opts *ChangedOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := config.FromContext(cmd.Context())
+
+	scope, err := loadEvaluableScope(cfg, opts.Scope)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	spinner := newEvalSpinner()
+	cancelSpinner := spinner.Start(context.Background())
+	defer cancelSpinner()
+
+	spinner.UpdateMessage("Loading options...")
+
+	options, err := scope.Loader()
+	if err != nil {
+		spinner.Stop()
+		log.Errorf("%v", err)
+		return err
+	}
+
+	var candidates []option.NixosOption
+	for _, o := range options {
+		if optionHasPrefix(o.Name, opts.Prefix) {
+			candidates = append(candidates, o)
+		}
+	}
+
+	if len(candidates) == 0 {
+		spinner.Stop()
+		err := fmt.Errorf("no options found with prefix '%v'", opts.Prefix)
+		log.Errorf("%v", err)
+		return err
+	}
+
+	results := evaluateOptions(scope, candidates, opts.Jobs, func(done int) {
+		spinner.UpdateMessage(fmt.Sprintf("Evaluating options (%d/%d)...", done, len(candidates)))
+	})
+
+	spinner.Stop()
+
+	changed := []changedOption{}
+	failed := 0
+	incomparable := 0
+
+	for i, r := range results {
+		o := &candidates[i]
+
+		if r.Err != nil {
+			failed++
+			continue
+		}
+
+		isDefault, ok := o.IsDefaultValue(r.Value)
+		if !ok {
+			incomparable++
+			continue
+		} else if isDefault {
+			continue
+		}
+
+		var defaultText *string
+		if o.Default != nil {
+			text := strings.TrimSpace(o.Default.Text)
+			defaultText = &text
+		}
+
+		changed = append(changed, changedOption{
+			Name:    o.Name,
+			Value:   r.Value,
+			Default: defaultText,
+		})
+	}
+
+	if opts.JSON {
+		bytes, _ := json.MarshalIndent(changed, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+	} else {
+		for _, c := range changed {
+			fmt.Printf("%v = %v;\n", c.Name, c.Value)
+		}
+	}
+
+	log.Infof("%d of %d options changed from their defaults", len(changed), len(candidates))
+	if incomparable > 0 {
+		log.Infof("%d options have defaults that could not be compared", incomparable)
+	}
+	if failed > 0 {
+		log.Warnf("%d options could not be evaluated", failed)
+	}
+
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/snapshot"
+	"snare.dev/optnix/option"
 )
 
 func GenerateCompletions(cmd *cobra.Command, shell string) {
@@ -24,6 +26,19 @@ func completeCompletionShells(cmd *cobra.Command, args []string, toComplete stri
 	return []string{"bash", "fish", "zsh"}, cobra.ShellCompDirectiveDefault
 }
 
+func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	formats := []string{
+		fmt.Sprintf("%s\t%s", outputFormatPretty, "Human-readable option information"),
+		fmt.Sprintf("%s\t%s", outputFormatValue, "Evaluated value only"),
+	}
+
+	for _, f := range option.OutputFormats {
+		formats = append(formats, fmt.Sprintf("%s\t%s", f, f.Description()))
+	}
+
+	return formats, cobra.ShellCompDirectiveNoFileComp
+}
+
 func completeOptionsFromScope(scopeName *string) cobra.CompletionFunc {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) > 1 || *scopeName == "" {
@@ -32,8 +47,8 @@ func completeOptionsFromScope(scopeName *string) cobra.CompletionFunc {
 
 		cfg := config.FromContext(cmd.Context())
 
-		scope, ok := cfg.Scopes[*scopeName]
-		if !ok {
+		scope, err := cfg.ResolveScope(*scopeName)
+		if err != nil {
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
 
@@ -61,10 +76,81 @@ func completeOptionsFromScope(scopeName *string) cobra.CompletionFunc {
 func completeScopes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	cfg := config.FromContext(cmd.Context())
 
+	if scope, values, ok := cfg.SplitScopeParams(toComplete); ok {
+		return completeScopeParams(scope, strings.TrimSuffix(toComplete, values), values)
+	}
+
+	directive := cobra.ShellCompDirectiveNoFileComp
+
 	scopes := []string{}
-	for name, scope := range cfg.Scopes {
-		scopes = append(scopes, fmt.Sprintf("%s\t%s", name, scope.Description))
+	for _, scope := range cfg.OrderedScopes() {
+		names := append([]string{scope.Name}, scope.Aliases...)
+
+		for i, name := range names {
+			description := scope.Description
+			if i > 0 {
+				description = fmt.Sprintf("alias for %v", scope.Name)
+			}
+
+			if scope.NeedsParams() {
+				// Parameter values must follow, so don't add a space.
+				scopes = append(scopes, fmt.Sprintf("%s%s\t%s", name, config.ScopeParamSeparator, description))
+				directive |= cobra.ShellCompDirectiveNoSpace
+				continue
+			}
+
+			scopes = append(scopes, fmt.Sprintf("%s\t%s", name, description))
+		}
+	}
+
+	// Keep the configured order instead of sorting alphabetically.
+	return scopes, directive | cobra.ShellCompDirectiveKeepOrder
+}
+
+// Complete the value of the parameter currently being typed for a
+// scope, using its `param-values-cmd`.
+func completeScopeParams(scope config.Scope, prefix string, values string) ([]string, cobra.ShellCompDirective) {
+	directive := cobra.ShellCompDirectiveNoFileComp
+
+	given := strings.Split(values, ",")
+	index := len(given) - 1
+	if index >= len(scope.Params) {
+		return nil, directive
+	}
+
+	choices, err := scope.ParamChoices(scope.Params[index])
+	if err != nil {
+		return nil, directive
+	}
+
+	if index > 0 {
+		prefix += strings.Join(given[:index], ",") + ","
+	}
+
+	// More values are needed after this one, so don't add a space.
+	if index < len(scope.Params)-1 {
+		directive |= cobra.ShellCompDirectiveNoSpace
+	}
+
+	var completions []string
+	for _, c := range choices {
+		if strings.HasPrefix(c, given[index]) {
+			completions = append(completions, prefix+c)
+		}
+	}
+
+	return completions, directive
+}
+
+func completeSnapshots(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names, err := snapshot.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	return scopes, cobra.ShellCompDirectiveNoFileComp
+	return names, cobra.ShellCompDirectiveNoFileComp
 }
@@ -0,0 +1,510 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	cmdUtils "snare.dev/optnix/internal/cmd/utils"
+	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/logger"
+)
+
+// Commands with this annotation run even if the configuration is
+// not valid, such as for inspecting or fixing it.
+const annotationSkipValidation = "optnix/skip-validation"
+
+var configCommandAnnotations = map[string]string{
+	annotationSkipTrustCheck: "true",
+	annotationSkipValidation: "true",
+}
+
+func ConfigCommand() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "config",
+		Short: "Inspect and modify configuration",
+		Long:  "Inspect the merged configuration and where each setting comes from, or modify configuration files.",
+	}
+
+	cmd.AddCommand(ConfigShowCommand())
+	cmd.AddCommand(ConfigGetCommand())
+	cmd.AddCommand(ConfigSetCommand())
+	cmd.AddCommand(ConfigPathsCommand())
+	cmd.AddCommand(ConfigSchemaCommand())
+	cmd.AddCommand(ConfigInitCommand())
+
+	return &cmd
+}
+
+type ConfigShowOpts struct {
+	JSON   bool
+	Prefix string
+}
+
+func ConfigShowCommand() *cobra.Command {
+	opts := ConfigShowOpts{}
+
+	cmd := cobra.Command{
+		Use:   "show [PREFIX]",
+		Short: "Show the merged configuration and where each setting comes from",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{Msg: err.Error()}
+			}
+			if len(args) > 0 {
+				opts.Prefix = args[0]
+			}
+			return nil
+		},
+		Annotations:       configCommandAnnotations,
+		ValidArgsFunction: completeConfigKeys,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := config.FromContext(cmd.Context())
+			printConfigValues(cfg, matchConfigKeys(cfg, opts.Prefix), opts.JSON)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.JSON, "json", "j", false, "Output settings in JSON format")
+
+	return &cmd
+}
+
+type ConfigGetOpts struct {
+	Origin bool
+	Key    string
+}
+
+func ConfigGetCommand() *cobra.Command {
+	opts := ConfigGetOpts{}
+
+	cmd := cobra.Command{
+		Use:   "get [KEY]",
+		Short: "Print the value of a setting",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "argument [KEY] is required",
+					Hint: `try running "optnix config show" to see all available keys`,
+				}
+			}
+			opts.Key = args[0]
+			return nil
+		},
+		Annotations:       configCommandAnnotations,
+		ValidArgsFunction: completeConfigKeys,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := configGetMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Origin, "origin", "o", false, "Print the file the setting comes from instead")
+
+	return &cmd
+}
+
+type ConfigSetOpts struct {
+	File  string
+	Key   string
+	Value string
+	Force bool
+}
+
+func ConfigSetCommand() *cobra.Command {
+	opts := ConfigSetOpts{}
+
+	cmd := cobra.Command{
+		Use:   "set [KEY] [VALUE]",
+		Short: "Set a value in a configuration file",
+		Long:  "Set a value in a configuration file. Values are parsed as TOML (i.e. 3, true, or [\"a\", \"b\"]), and are treated as strings otherwise. The new configuration is validated before it is written, and files with comments are only modified with --force.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "arguments [KEY] and [VALUE] are required",
+					Hint: `try running "optnix config set default_scope nixos"`,
+				}
+			}
+
+			opts.Key = args[0]
+			opts.Value = args[1]
+
+			if opts.File == "" {
+				opts.File = config.UserConfigLocation
+			}
+			if opts.File == "" {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "unable to determine the user configuration file location",
+					Hint: "specify a file to modify with --file",
+				}
+			}
+
+			return nil
+		},
+		Annotations: configCommandAnnotations,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return completeConfigKeys(cmd, args, toComplete)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := configSetMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.File, "file", "f", "", "Configuration `file` to modify (default: user configuration)")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Modify the file even if it has comments, which are removed")
+
+	return &cmd
+}
+
+func ConfigPathsCommand() *cobra.Command {
+	cmd := cobra.Command{
+		Use:         "paths",
+		Short:       "List configuration file locations, from lowest to highest priority",
+		Args:        cobra.NoArgs,
+		Annotations: configCommandAnnotations,
+		Run: func(cmd *cobra.Command, args []string) {
+			configPathsMain(cmd)
+		},
+	}
+
+	return &cmd
+}
+
+func ConfigSchemaCommand() *cobra.Command {
+	cmd := cobra.Command{
+		Use:         "schema",
+		Short:       "Print a JSON Schema of the configuration format",
+		Long:        "Print a JSON Schema of the configuration format, which can be used by editors to validate configuration files.",
+		Args:        cobra.NoArgs,
+		Annotations: configCommandAnnotations,
+		Run: func(cmd *cobra.Command, args []string) {
+			bytes, _ := json.MarshalIndent(config.Schema(), "", "  ")
+			fmt.Printf("%v\n", string(bytes))
+		},
+	}
+
+	return &cmd
+}
+
+// Find the keys that are either the given key, or are under it.
+func matchConfigKeys(cfg *config.Config, prefix string) []string {
+	var keys []string
+	for key := range cfg.Values() {
+		if prefix == "" || key == prefix || strings.HasPrefix(key, strings.TrimSuffix(prefix, ".")+".") {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+var configOriginColor = color.New(color.FgHiBlack)
+
+const configOriginDefault = "(default)"
+
+func configKeyOrigin(cfg *config.Config, key string) string {
+	if origin := cfg.FieldOrigin(key); origin != "" {
+		return origin
+	}
+	return configOriginDefault
+}
+
+type configValueRecord struct {
+	Value  any    `json:"value"`
+	Origin string `json:"origin"`
+}
+
+func printConfigValues(cfg *config.Config, keys []string, asJSON bool) {
+	values := cfg.Values()
+
+	if asJSON {
+		records := make(map[string]configValueRecord, len(keys))
+		for _, key := range keys {
+			records[key] = configValueRecord{
+				Value:  values[key],
+				Origin: configKeyOrigin(cfg, key),
+			}
+		}
+
+		bytes, _ := json.MarshalIndent(records, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+		return
+	}
+
+	lines := make([]string, len(keys))
+	width := 0
+
+	for i, key := range keys {
+		lines[i] = fmt.Sprintf("%v = %v", key, config.FormatValue(values[key]))
+		width = max(width, len(lines[i]))
+	}
+
+	// Long values would push all origins too far to the right.
+	width = min(width, 60)
+
+	for i, key := range keys {
+		fmt.Printf("%-*v  %v\n", width, lines[i], configOriginColor.Sprintf("# %v", configKeyOrigin(cfg, key)))
+	}
+}
+
+func configGetMain(cmd *cobra.Command, opts *ConfigGetOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := config.FromContext(cmd.Context())
+
+	values := cfg.Values()
+
+	value, ok := values[opts.Key]
+	if !ok {
+		// Allow getting entire tables, such as a scope.
+		keys := matchConfigKeys(cfg, opts.Key)
+		if len(keys) == 0 {
+			err := fmt.Errorf("key '%v' is not set", opts.Key)
+			log.Errorf("%v", err)
+			log.Info(`try running "optnix config show" to see all available keys`)
+			return err
+		}
+
+		printConfigValues(cfg, keys, false)
+		return nil
+	}
+
+	if opts.Origin {
+		fmt.Println(configKeyOrigin(cfg, opts.Key))
+		return nil
+	}
+
+	if s, ok := value.(string); ok {
+		fmt.Println(s)
+	} else {
+		fmt.Println(config.FormatValue(value))
+	}
+
+	return nil
+}
+
+func configSetMain(cmd *cobra.Command, opts *ConfigSetOpts) error {
+	log := logger.FromContext(cmd.Context())
+
+	path, err := filepath.Abs(opts.File)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	value := config.ParseValue(opts.Value)
+
+	var mode os.FileMode = 0o644
+	original, err := os.ReadFile(path)
+	if err == nil {
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	// The file is rewritten from its parsed values, so comments
+	// in it would be lost.
+	if !opts.Force {
+		hasComments, err := config.HasComments(path, original)
+		if err == nil && hasComments {
+			err := fmt.Errorf("%v has comments, which would be removed by modifying it", path)
+			log.Errorf("%v", err)
+			log.Info("edit the file by hand instead, or use --force to modify it anyway")
+			return err
+		}
+	}
+
+	contents, err := config.SetFileValue(path, opts.Key, value)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Errorf("failed to create configuration directory: %v", err)
+		return err
+	}
+
+	// Validate the new configuration as a whole before writing it,
+	// using a file next to the original one so that relative paths
+	// still work. The original file is then replaced all at once,
+	// so nothing ever sees a configuration that was not validated.
+	tmp, err := writeTempConfig(path, contents, mode)
+	if err != nil {
+		log.Errorf("failed to write %v: %v", path, err)
+		return err
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	if err := validateConfigLocations(cmd, path, tmp); err != nil {
+		var validationErr config.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Origin == tmp {
+			validationErr.Origin = path
+			err = validationErr
+		}
+
+		log.Errorf("%v", err)
+		log.Warnf("%v was not modified", path)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		log.Errorf("failed to write %v: %v", path, err)
+		return err
+	}
+
+	log.Infof("set %v = %v in %v", opts.Key, config.FormatValue(value), path)
+
+	return nil
+}
+
+// Write the new contents of a configuration file to a temporary file
+// in the same directory, with the same extension so that it is read
+// in the same format.
+func writeTempConfig(path string, contents []byte, mode os.FileMode) (string, error) {
+	ext := filepath.Ext(path)
+	pattern := fmt.Sprintf(".%v.*%v", strings.TrimSuffix(filepath.Base(path), ext), ext)
+
+	f, err := os.CreateTemp(filepath.Dir(path), pattern)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(contents)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// Parse and validate all configuration files, with the file at path
+// replaced by another one. The file does not have to be one that is
+// normally loaded.
+func validateConfigLocations(cmd *cobra.Command, path string, replacement string) error {
+	extraConfigs, _ := cmd.Flags().GetStringSlice("config")
+
+	locations := slices.Concat(defaultConfigLocations(cmd), config.ProjectConfigLocations(), extraConfigs)
+
+	found := false
+	for i, loc := range locations {
+		if abs, err := filepath.Abs(loc); err == nil && abs == path {
+			locations[i] = replacement
+			found = true
+		}
+	}
+	if !found {
+		locations = append(locations, replacement)
+	}
+
+	cfg, err := config.ParseConfig(locations...)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+func configPathsMain(cmd *cobra.Command) {
+	cfg := config.FromContext(cmd.Context())
+	extraConfigs, _ := cmd.Flags().GetStringSlice("config")
+
+	type row struct {
+		path  string
+		layer string
+	}
+
+	_, fromEnv := os.LookupEnv(config.ConfigLocationsEnvVar)
+
+	var rows []row
+	for _, loc := range defaultConfigLocations(cmd) {
+		layer := "system"
+		if fromEnv {
+			layer = "env"
+			if abs, err := filepath.Abs(loc); err == nil {
+				loc = abs
+			}
+		} else if loc == config.UserConfigLocation {
+			layer = "user"
+		}
+		rows = append(rows, row{loc, layer})
+	}
+
+	projectLocations := config.ProjectConfigLocations()
+	if len(projectLocations) == 0 {
+		rows = append(rows, row{strings.Join(config.ProjectConfigNames, ", "), "project"})
+	}
+	for _, loc := range projectLocations {
+		rows = append(rows, row{loc, "project"})
+	}
+
+	for _, loc := range extraConfigs {
+		if abs, err := filepath.Abs(loc); err == nil {
+			loc = abs
+		}
+		rows = append(rows, row{loc, "--config"})
+	}
+
+	// Included files are loaded right after the file that includes them.
+	var withIncludes []row
+	for _, r := range rows {
+		withIncludes = append(withIncludes, r)
+		for _, loc := range cfg.IncludedFiles(r.path) {
+			withIncludes = append(withIncludes, row{loc, "include"})
+		}
+	}
+	rows = withIncludes
+
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r.path))
+	}
+
+	loaded := cfg.Locations()
+	for _, r := range rows {
+		status := configOriginColor.Sprint("not found")
+		if slices.Contains(loaded, r.path) {
+			status = "loaded"
+		}
+
+		fmt.Printf("%-*v  %-8v  %v\n", width, r.path, r.layer, status)
+	}
+}
+
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg := config.FromContext(cmd.Context())
+
+	var keys []string
+	for key := range cfg.Values() {
+		if strings.HasPrefix(key, toComplete) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
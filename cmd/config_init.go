@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cmdUtils "snare.dev/optnix/internal/cmd/utils"
+	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/logger"
+)
+
+type ConfigInitOpts struct {
+	File  string
+	Yes   bool
+	Force bool
+	Print bool
+}
+
+func ConfigInitCommand() *cobra.Command {
+	opts := ConfigInitOpts{}
+
+	cmd := cobra.Command{
+		Use:   "init",
+		Short: "Generate a configuration file interactively",
+		Long:  "Generate a configuration file with scopes for NixOS, home-manager, and nix-darwin configurations, including ones found in a flake.nix in the current directory.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{Msg: err.Error()}
+			}
+
+			if opts.File == "" {
+				opts.File = config.UserConfigLocation
+			}
+			if opts.File == "" && !opts.Print {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "unable to determine the user configuration file location",
+					Hint: "specify a file to write with --file",
+				}
+			}
+
+			return nil
+		},
+		Annotations: configCommandAnnotations,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := configInitMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.File, "file", "f", "", "Configuration `file` to write (default: user configuration)")
+	cmd.Flags().BoolVarP(&opts.Yes, "yes", "y", false, "Accept default answers without asking")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Overwrite the file if it already exists")
+	cmd.Flags().BoolVarP(&opts.Print, "print", "p", false, "Print the generated configuration instead of writing it")
+
+	return &cmd
+}
+
+// Asks questions on stderr and reads answers from stdin, so that the
+// generated configuration can be printed to stdout separately.
+type configPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	yes bool
+}
+
+func (p *configPrompter) ask(question string, def string) (string, error) {
+	if p.yes {
+		return def, nil
+	}
+
+	if def != "" {
+		fmt.Fprintf(p.out, "%v [%v]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%v: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if errors.Is(err, io.EOF) && line == "" {
+		// Accept defaults when input ends, such as when piped.
+		fmt.Fprintln(p.out)
+		return def, nil
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+func (p *configPrompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+
+	for {
+		answer, err := p.ask(fmt.Sprintf("%v (%v)", question, hint), "")
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+
+		fmt.Fprintln(p.out, "please answer yes or no")
+	}
+}
+
+func (p *configPrompter) choose(question string, choices []string) (string, error) {
+	for i, c := range choices {
+		fmt.Fprintf(p.out, "  %d) %v\n", i+1, c)
+	}
+
+	for {
+		answer, err := p.ask(question, "1")
+		if err != nil {
+			return "", err
+		}
+
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(choices) {
+			return choices[i-1], nil
+		}
+
+		for _, c := range choices {
+			if c == answer {
+				return c, nil
+			}
+		}
+
+		fmt.Fprintf(p.out, "please choose a number between 1 and %d\n", len(choices))
+	}
+}
+
+type generatedScope struct {
+	name  string
+	scope config.Scope
+}
+
+func configInitMain(cmd *cobra.Command, opts *ConfigInitOpts) error {
+	log := logger.FromContext(cmd.Context())
+
+	var path string
+	if !opts.Print {
+		var err error
+		path, err = filepath.Abs(opts.File)
+		if err != nil {
+			log.Errorf("%v", err)
+			return err
+		}
+
+		if _, err := os.Stat(path); err == nil && !opts.Force {
+			err := fmt.Errorf("%v already exists", path)
+			log.Errorf("%v", err)
+			log.Info("use --force to overwrite it, or --file to write somewhere else")
+			return err
+		}
+	}
+
+	p := &configPrompter{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stderr,
+		yes: opts.Yes,
+	}
+
+	scopes, err := promptScopes(p)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	if len(scopes) == 0 {
+		err := fmt.Errorf("no scopes were selected")
+		log.Errorf("%v", err)
+		return err
+	}
+
+	defaultScope := scopes[0].name
+	if len(scopes) > 1 {
+		names := make([]string, len(scopes))
+		for i, s := range scopes {
+			names[i] = s.name
+		}
+
+		defaultScope, err = p.choose("Default scope", names)
+		if err != nil {
+			log.Errorf("%v", err)
+			return err
+		}
+	}
+
+	contents, err := generateConfig(scopes, defaultScope, opts.File)
+	if err != nil {
+		log.Errorf("failed to generate configuration: %v", err)
+		return err
+	}
+
+	if err := validateGeneratedConfig(contents, opts.File); err != nil {
+		log.Errorf("generated configuration is not valid: %v", err)
+		return err
+	}
+
+	if opts.Print {
+		fmt.Print(string(contents))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Errorf("failed to create configuration directory: %v", err)
+		return err
+	}
+
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		log.Errorf("failed to write %v: %v", path, err)
+		return err
+	}
+
+	log.Infof("wrote %d scope(s) to %v", len(scopes), path)
+
+	return nil
+}
+
+// Ask which scopes to generate, based on the configurations that
+// are found in a flake in the current directory, if any.
+func promptScopes(p *configPrompter) ([]generatedScope, error) {
+	var scopes []generatedScope
+
+	flake, hasFlake := config.FindFlake(".")
+	if hasFlake {
+		useFlake, err := p.confirm(fmt.Sprintf("Found %v, look for configurations in it?", filepath.Join(flake, "flake.nix")), true)
+		if err != nil {
+			return nil, err
+		}
+		hasFlake = useFlake
+	}
+
+	if !hasFlake {
+		_, isNixOS := os.Stat("/etc/NIXOS")
+
+		add, err := p.confirm("Add a scope for this NixOS system, using <nixpkgs/nixos>?", isNixOS == nil)
+		if err != nil {
+			return nil, err
+		}
+		if add {
+			scopes = append(scopes, generatedScope{name: "nixos", scope: config.NewLegacyNixOSScope()})
+		}
+
+		return scopes, nil
+	}
+
+	spinner := newEvalSpinner()
+	cancelSpinner := spinner.Start(context.Background())
+	spinner.UpdateMessage("Looking for configurations...")
+
+	configurations, err := config.Scope{Flake: flake}.FlakeConfigurations()
+
+	spinner.Stop()
+	cancelSpinner()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kind := range config.FlakeConfigurationKinds {
+		names := configurations[kind.Attr]
+		if len(names) == 0 {
+			continue
+		}
+
+		add, err := p.confirm(fmt.Sprintf("Add scopes for %v configurations (%v)?", kind.Name, strings.Join(names, ", ")), true)
+		if err != nil {
+			return nil, err
+		}
+		if !add {
+			continue
+		}
+
+		for _, name := range names {
+			// Only disambiguate scope names when there is more
+			// than one configuration of a kind.
+			scopeName := kind.ScopeName
+			if len(names) > 1 {
+				scopeName = fmt.Sprintf("%v-%v", kind.ScopeName, strings.ReplaceAll(name, ".", "-"))
+			}
+
+			scopes = append(scopes, generatedScope{
+				name:  scopeName,
+				scope: config.NewFlakeScope(flake, kind, name),
+			})
+		}
+	}
+
+	return scopes, nil
+}
+
+// Generate a configuration file in the format of the file it will be
+// written to.
+func generateConfig(scopes []generatedScope, defaultScope string, file string) ([]byte, error) {
+	scopeValues := make(map[string]any, len(scopes))
+	for _, s := range scopes {
+		scopeValues[s.name] = config.ScopeValues(s.scope)
+	}
+
+	contents, err := config.ParserFor(file).Marshal(map[string]any{
+		"default_scope": defaultScope,
+		"scopes":        scopeValues,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON does not have comments.
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		return contents, nil
+	}
+
+	header := "# Generated by `optnix config init`. See optnix.toml(5) for\n# all available settings.\n\n"
+
+	return append([]byte(header), contents...), nil
+}
+
+// Validate generated configuration on its own, without any other
+// configuration files that may exist.
+func validateGeneratedConfig(contents []byte, file string) error {
+	f, err := os.CreateTemp("", "optnix-*"+filepath.Ext(file))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(contents); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	cfg, err := config.ParseConfig(f.Name())
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
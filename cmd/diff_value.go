@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+	cmdUtils "snare.dev/optnix/internal/cmd/utils"
+	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/diff"
+	"snare.dev/optnix/internal/logger"
+	"snare.dev/optnix/option"
+)
+
+type DiffValueOpts struct {
+	Scopes      []string
+	OptionInput string
+}
+
+func DiffValueCommand() *cobra.Command {
+	opts := DiffValueOpts{}
+
+	cmd := cobra.Command{
+		Use:   "diff-value -s [SCOPE-A] -s [SCOPE-B] [OPTION-NAME]",
+		Short: "Compare an option's value between two scopes",
+		Long:  "Evaluate an option in two scopes and display the differences between the values.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "argument [OPTION-NAME] is required",
+					Hint: `try running "optnix diff-value -s [SCOPE-A] -s [SCOPE-B] [OPTION-NAME]"`,
+				}
+			}
+
+			if len(opts.Scopes) != 2 {
+				return cmdUtils.ErrorWithHint{
+					Msg:  fmt.Sprintf("exactly two scopes are required, got %v", len(opts.Scopes)),
+					Hint: "specify the scopes to compare with -s, i.e. `-s hostA -s hostB`",
+				}
+			}
+
+			opts.OptionInput = args[0]
+
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(opts.Scopes) == 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return completeOptionsFromScope(&opts.Scopes[0])(cmd, args, toComplete)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := diffValueMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&opts.Scopes, "scope", "s", nil, "Scope `name` to compare (specify twice)")
+
+	_ = cmd.RegisterFlagCompletionFunc("scope", completeScopes)
+
+	return &cmd
+}
+
+type scopeEvalResult struct {
+	Value string
+	Err   error
+}
+
+// Evaluate an option in multiple scopes at the same time.
+func evaluateInScopes(scopes []option.Scope, optionName string) []scopeEvalResult {
+	results := make([]scopeEvalResult, len(scopes))
+
+	var wg sync.WaitGroup
+	for i := range scopes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Value, results[i].Err = scopes[i].EvaluateNix(optionName, nil)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func diffValueMain(cmd *cobra.Command, opts *DiffValueOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := config.FromContext(cmd.Context())
+
+	formatter := constructValueFormatter(cfg)
+
+	scopes := make([]option.Scope, len(opts.Scopes))
+	for i, name := range opts.Scopes {
+		s, err := cfg.ResolveScope(name)
+		if err != nil {
+			log.Errorf("%v", err)
+			return err
+		}
+
+		scopes[i] = constructScopeFromConfig(&s, formatter)
+	}
+
+	spinner := pin.New(fmt.Sprintf("Evaluating %v...", opts.OptionInput),
+		pin.WithSpinnerColor(pin.ColorCyan),
+		pin.WithTextColor(pin.ColorRed),
+		pin.WithPosition(pin.PositionRight),
+		pin.WithSpinnerFrames([]rune{'-', '\\', '|', '/'}),
+		pin.WithWriter(os.Stderr),
+	)
+	cancelSpinner := spinner.Start(context.Background())
+	defer cancelSpinner()
+
+	results := evaluateInScopes(scopes, opts.OptionInput)
+
+	spinner.Stop()
+
+	var evalErr error
+	for i, r := range results {
+		if r.Err == nil {
+			continue
+		}
+
+		evalErr = r.Err
+		log.Errorf("failed to evaluate %v in scope '%v': %v", opts.OptionInput, scopes[i].Name, r.Err)
+		if e, ok := r.Err.(*option.AttributeEvaluationError); ok {
+			log.Print(e.EvaluationOutput + "\n")
+		}
+	}
+	if evalErr != nil {
+		return evalErr
+	}
+
+	lines := diff.Lines(
+		option.ExpandNixValue(results[0].Value, cfg.FormatterIndent),
+		option.ExpandNixValue(results[1].Value, cfg.FormatterIndent),
+	)
+	if !diff.HasChanges(lines) {
+		log.Infof("%v has the same value in scopes '%v' and '%v'", opts.OptionInput, scopes[0].Name, scopes[1].Name)
+		return nil
+	}
+
+	fmt.Print(formatDiff(lines, scopes[0].Name, scopes[1].Name))
+
+	return nil
+}
+
+var (
+	diffDeleteColor = color.New(color.FgRed)
+	diffInsertColor = color.New(color.FgGreen)
+	diffHeaderColor = color.New(color.Bold)
+)
+
+func formatDiff(lines []diff.Line, oldName string, newName string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%v\n%v\n", diffHeaderColor.Sprintf("--- %v", oldName), diffHeaderColor.Sprintf("+++ %v", newName))
+
+	for _, l := range lines {
+		switch l.Op {
+		case diff.OpDelete:
+			fmt.Fprintf(&sb, "%v\n", diffDeleteColor.Sprint("-"+l.Text))
+		case diff.OpInsert:
+			fmt.Fprintf(&sb, "%v\n", diffInsertColor.Sprint("+"+l.Text))
+		default:
+			fmt.Fprintf(&sb, " %v\n", l.Text)
+		}
+	}
+
+	return sb.String()
+}
@@ -8,7 +8,6 @@ import (
 	"os"
 	"slices"
 	"strings"
-	"text/template"
 	"unicode/utf8"
 
 	"github.com/fatih/color"
@@ -44,17 +43,25 @@ Commands:{{range $cmds}}{{if (and (eq .GroupID "") (or .IsAvailableCommand (eq .
   {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
 
 Flags:
-{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
+
+Global Flags:
+{{.InheritedFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableSubCommands}}
+
+Use "{{.CommandPath}} [command] --help" for more information about a command.{{end}}
 `
 
 type CmdOptions struct {
 	NonInteractive      bool
 	Config              []string
+	NoDefaultConfig     bool
 	JSON                bool
 	MinScore            int64
 	ValueOnly           bool
+	Format              string
 	Scope               string
 	ListScopes          bool
+	Watch               bool
 	GenerateCompletions string
 
 	OptionInput string
@@ -90,8 +97,10 @@ func CreateCommand() *cobra.Command {
 				opts.OptionInput = args[0]
 			}
 
+			formatChanged := cmd.Flags().Changed("format")
+
 			// Imply `--non-interactive` for scripting output if not specified
-			if opts.JSON || opts.ValueOnly {
+			if opts.JSON || opts.ValueOnly || formatChanged {
 				if cmd.Flags().Changed("non-interactive") && !opts.NonInteractive {
 					return cmdUtils.ErrorWithHint{Msg: "--non-interactive is required when using output format flags"}
 				}
@@ -103,6 +112,27 @@ func CreateCommand() *cobra.Command {
 				return cmdUtils.ErrorWithHint{Msg: "--json and --value-only flags conflict"}
 			}
 
+			if formatChanged && (opts.JSON || opts.ValueOnly) {
+				return cmdUtils.ErrorWithHint{Msg: "--format conflicts with --json and --value-only"}
+			}
+
+			if opts.JSON {
+				opts.Format = string(option.OutputFormatJSON)
+			} else if opts.ValueOnly {
+				opts.Format = outputFormatValue
+			}
+
+			if !slices.Contains(availableOutputFormats(), opts.Format) {
+				return cmdUtils.ErrorWithHint{
+					Msg:  fmt.Sprintf("unsupported output format '%v'", opts.Format),
+					Hint: fmt.Sprintf("supported formats are %v", strings.Join(availableOutputFormats(), ", ")),
+				}
+			}
+
+			if opts.NonInteractive && opts.Watch {
+				return cmdUtils.ErrorWithHint{Msg: "--watch is only supported in interactive mode"}
+			}
+
 			if opts.NonInteractive && argc < 1 {
 				scopeName := opts.Scope
 				if scopeName == "" {
@@ -129,9 +159,8 @@ func CreateCommand() *cobra.Command {
 				return nil
 			}
 
-			inCompletionMode := cmd.CalledAs() == cobra.ShellCompRequestCmd
-
-			configLocations := append(config.DefaultConfigLocations, opts.Config...)
+			projectConfigLocations := config.ProjectConfigLocations()
+			configLocations := slices.Concat(defaultConfigLocations(cmd), projectConfigLocations, opts.Config)
 
 			cfg, err := config.ParseConfig(configLocations...)
 			if err != nil {
@@ -139,20 +168,11 @@ func CreateCommand() *cobra.Command {
 				return err
 			}
 
-			if !inCompletionMode {
-				if err := cfg.Validate(); err != nil {
-					return err
-				}
+			if err := prepareConfig(cmd, cfg, projectConfigLocations); err != nil {
+				return err
 			}
 
 			if opts.Scope == "" {
-				if cfg.DefaultScope == "" && !inCompletionMode && !opts.ListScopes {
-					return cmdUtils.ErrorWithHint{
-						Msg:  "no scope was provided and no default scope is set in the configuration",
-						Hint: "either set a default configuration or specify one with -s",
-					}
-				}
-
 				opts.Scope = cfg.DefaultScope
 			}
 
@@ -165,6 +185,20 @@ func CreateCommand() *cobra.Command {
 
 			return nil
 		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.GenerateCompletions != "" || opts.ListScopes {
+				return nil
+			}
+
+			if opts.Scope == "" {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "no scope was provided and no default scope is set in the configuration",
+					Hint: "either set a default configuration or specify one with -s",
+				}
+			}
+
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if err := commandMain(cmd, &opts); err != nil {
 				os.Exit(1)
@@ -187,20 +221,88 @@ func CreateCommand() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.NonInteractive, "non-interactive", "n", false, "Do not show search TUI for options")
 	cmd.Flags().BoolVarP(&opts.JSON, "json", "j", false, "Output information in JSON format")
 	cmd.Flags().BoolVarP(&opts.ListScopes, "list-scopes", "l", false, "List available scopes and exit")
+	cmd.Flags().BoolVarP(&opts.Watch, "watch", "w", false, "Reload when configuration or options list files change")
 	cmd.Flags().Int64VarP(&opts.MinScore, "min-score", "m", 0, "Minimum `score` threshold for matching")
-	cmd.Flags().StringSliceVarP(&opts.Config, "config", "c", nil, "Path to extra configuration `files` to load")
 	cmd.Flags().BoolVarP(&opts.ValueOnly, "value-only", "v", false, "Only show option values")
+	cmd.Flags().StringVarP(&opts.Format, "format", "f", outputFormatPretty, "Output `format` to display option information in")
+
+	cmd.PersistentFlags().StringSliceVarP(&opts.Config, "config", "c", nil, "Path to extra configuration `files` to load")
+	cmd.PersistentFlags().BoolVar(&opts.NoDefaultConfig, "no-default-config", false, "Do not load system and user configuration files")
 
 	cmd.Flags().StringVar(&opts.GenerateCompletions, "completion", "", "Generate completions for a shell")
 	_ = cmd.Flags().MarkHidden("completion")
 
 	_ = cmd.RegisterFlagCompletionFunc("scope", completeScopes)
 	_ = cmd.RegisterFlagCompletionFunc("completion", completeCompletionShells)
+	_ = cmd.RegisterFlagCompletionFunc("format", completeOutputFormats)
+
+	cmd.AddCommand(DiffValueCommand())
+	cmd.AddCommand(ChangedCommand())
+	cmd.AddCommand(SnapshotCommand())
+	cmd.AddCommand(TrustCommand())
+	cmd.AddCommand(UntrustCommand())
+	cmd.AddCommand(ConfigCommand())
 
 	return &cmd
 }
 
-var scopesListHeader = []string{"Name", "Description", "Origin"}
+const (
+	outputFormatPretty = "pretty"
+	outputFormatValue  = "value"
+)
+
+// Output formats that can be passed using `--format`; this includes
+// all option output formats, plus some that only make sense for
+// displaying evaluated values on the command line.
+func availableOutputFormats() []string {
+	formats := []string{outputFormatPretty, outputFormatValue}
+	for _, f := range option.OutputFormats {
+		formats = append(formats, string(f))
+	}
+	return formats
+}
+
+// Check that project configuration files are trusted, then expand and
+// validate the configuration, as far as the command allows.
+func prepareConfig(cmd *cobra.Command, cfg *config.Config, projectConfigLocations []string) error {
+	inCompletionMode := cmd.CalledAs() == cobra.ShellCompRequestCmd
+
+	if cmd.Annotations[annotationSkipTrustCheck] == "" {
+		// Files included by project configuration files can
+		// come from the same untrusted source as they do.
+		trustLocations := slices.Concat(projectConfigLocations, cfg.IncludedFiles(projectConfigLocations...))
+		if err := checkProjectConfigTrust(cfg, trustLocations); err != nil {
+			return err
+		}
+	}
+
+	// Scopes generated from flakes need commands to be run to
+	// discover them, so only trusted configurations can be
+	// expanded, and not when inspecting configuration.
+	if cmd.Annotations[annotationSkipTrustCheck] == "" && cmd.Annotations[annotationSkipValidation] == "" {
+		if err := cfg.ExpandFlakeScopes(); err != nil && !inCompletionMode {
+			return err
+		}
+	}
+
+	if !inCompletionMode && cmd.Annotations[annotationSkipValidation] == "" {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Configuration files to load before project configuration files,
+// which are skipped entirely with `--no-default-config`.
+func defaultConfigLocations(cmd *cobra.Command) []string {
+	if noDefault, _ := cmd.Flags().GetBool("no-default-config"); noDefault {
+		return nil
+	}
+
+	return config.DefaultLocations()
+}
 
 func centered(width int, s string) *bytes.Buffer {
 	var b bytes.Buffer
@@ -222,14 +324,40 @@ func centered(width int, s string) *bytes.Buffer {
 func listScopes(cfg *config.Config) {
 	const separator = " | "
 
-	data := make([][]string, 0, len(cfg.Scopes))
+	scopes := cfg.OrderedScopes()
+
+	// Only show groups and aliases if any scope has them.
+	hasGroups := slices.ContainsFunc(scopes, func(s config.Scope) bool { return s.Group != "" })
+	hasAliases := slices.ContainsFunc(scopes, func(s config.Scope) bool { return len(s.Aliases) > 0 })
+
+	header := []string{"Name"}
+	if hasGroups {
+		header = append(header, "Group")
+	}
+	if hasAliases {
+		header = append(header, "Aliases")
+	}
+	header = append(header, "Description", "Origin")
+
+	data := make([][]string, 0, len(scopes))
+
+	for _, v := range scopes {
+		row := []string{v.Usage()}
+		if hasGroups {
+			row = append(row, v.Group)
+		}
+		if hasAliases {
+			row = append(row, strings.Join(v.Aliases, ", "))
+		}
 
-	for s, v := range cfg.Scopes {
-		origin := cfg.FieldOrigin(fmt.Sprintf("scopes.%v", s))
-		data = append(data, []string{s, v.Description, origin})
+		origin := cfg.FieldOrigin(fmt.Sprintf("scopes.%v", v.Name))
+		data = append(data, append(row, v.Description, origin))
 	}
 
-	colWidths := make([]int, len(scopesListHeader))
+	colWidths := make([]int, len(header))
+	for i, col := range header {
+		colWidths[i] = len(col)
+	}
 
 	for _, row := range data {
 		for i, col := range row {
@@ -241,11 +369,11 @@ func listScopes(cfg *config.Config) {
 
 	totalWidth := 0
 
-	for i, col := range scopesListHeader {
+	for i, col := range header {
 		fmt.Print(centered(colWidths[i], col))
 		totalWidth += colWidths[i]
 
-		if i < len(scopesListHeader)-1 {
+		if i < len(header)-1 {
 			fmt.Print(separator)
 			totalWidth += len(separator)
 		}
@@ -265,42 +393,143 @@ func listScopes(cfg *config.Config) {
 	}
 }
 
-func constructScopeFromConfig(scope *config.Scope, formatterCmd string) option.Scope {
+func constructValueFormatter(cfg *config.Config) option.NixValueFormatter {
+	return option.NixValueFormatter{
+		Cmd:    cfg.FormatterCmd,
+		Indent: cfg.FormatterIndent,
+		Width:  cfg.FormatterWidth,
+	}
+}
+
+func constructScopeFromConfig(scope *config.Scope, formatter option.NixValueFormatter) option.Scope {
 	loader := func() (option.NixosOptionSource, error) {
 		return scope.Load()
 	}
 
-	evaluator := constructEvaluatorFromScope(formatterCmd, scope)
+	evaluator := constructEvaluatorFromScope(formatter, scope)
 
-	return option.Scope{
+	s := option.Scope{
 		Name:        scope.Name,
 		Description: scope.Description,
+		Group:       scope.Group,
 		Loader:      loader,
 		Evaluator:   evaluator,
+
+		EvaluatorOutput: option.EvaluatorOutput(scope.EvaluatorOutput),
+		BatchEvaluator:  constructBatchEvaluatorFromScope(scope),
+		Definitions:     constructDefinitionsFromScope(scope),
+	}
+
+	if scope.NeedsParams() {
+		unbound := *scope
+
+		s.Parameters = unbound.Params
+		s.Bind = func(values string) (option.Scope, error) {
+			bound, err := unbound.Bind(values)
+			if err != nil {
+				return option.Scope{}, err
+			}
+			return constructScopeFromConfig(&bound, formatter), nil
+		}
 	}
+
+	return s
 }
 
-func constructEvaluatorFromScope(formatterCmd string, s *config.Scope) option.EvaluatorFunc {
-	if s.EvaluatorCmd == "" {
+func constructBatchEvaluatorFromScope(s *config.Scope) option.BatchEvaluatorFunc {
+	if s.BatchEvaluatorCmd.IsEmpty() {
 		return nil
 	}
 
-	tmpl, err := template.New("eval").Parse(s.EvaluatorCmd)
+	tmpl, err := config.ParseCommandTemplate("batch-evaluator", s.BatchEvaluatorCmd)
 	if err != nil {
-		panic(fmt.Sprintf("evaluator should have been verified as valid at this point: %v", err))
+		panic(fmt.Sprintf("batch evaluator should have been verified as valid at this point: %v", err))
 	}
 
-	return func(optionName string) (string, error) {
-		var buf bytes.Buffer
+	return func(optionNames []string, locations [][]string) ([]string, error) {
+		command, err := tmpl.Execute(config.NewBatchCommandTemplateData(*s, optionNames, locations))
+		if err != nil {
+			return nil, err
+		}
 
-		err := tmpl.Execute(&buf, map[string]string{
-			"Option": optionName,
-		})
+		cmdOutput, err := s.Exec(command)
+		if err != nil {
+			return nil, fmt.Errorf("batch evaluator failed: %v", strings.TrimSpace(cmdOutput.Stderr))
+		}
+
+		var values []json.RawMessage
+		if err := json.Unmarshal([]byte(cmdOutput.Stdout), &values); err != nil {
+			return nil, fmt.Errorf("batch evaluator did not return a JSON list: %v", err)
+		}
+
+		if len(values) != len(optionNames) {
+			return nil, fmt.Errorf("batch evaluator returned %d values for %d options", len(values), len(optionNames))
+		}
+
+		result := make([]string, len(values))
+		for i, v := range values {
+			result[i] = string(v)
+		}
+
+		return result, nil
+	}
+}
+
+func constructDefinitionsFromScope(s *config.Scope) option.DefinitionsFunc {
+	if s.DefinitionsCmd.IsEmpty() {
+		return nil
+	}
+
+	tmpl, err := config.ParseCommandTemplate("definitions", s.DefinitionsCmd)
+	if err != nil {
+		panic(fmt.Sprintf("definitions command should have been verified as valid at this point: %v", err))
+	}
+
+	return func(optionName string, location []string) ([]option.OptionDefinition, error) {
+		command, err := tmpl.Execute(config.NewCommandTemplateData(*s, optionName, location))
+		if err != nil {
+			return nil, err
+		}
+
+		cmdOutput, err := s.Exec(command)
+		if err != nil {
+			output := strings.TrimSpace(cmdOutput.Stderr)
+			if output == "" {
+				output = err.Error()
+			}
+
+			return nil, &option.AttributeEvaluationError{
+				Attribute:        optionName,
+				EvaluationOutput: output,
+			}
+		}
+
+		definitions, err := option.ParseOptionDefinitions(cmdOutput.Stdout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid definitions output: %v", err)
+		}
+
+		return definitions, nil
+	}
+}
+
+func constructEvaluatorFromScope(formatter option.NixValueFormatter, s *config.Scope) option.EvaluatorFunc {
+	if s.EvaluatorCmd.IsEmpty() {
+		return nil
+	}
+
+	tmpl, err := config.ParseCommandTemplate("evaluator", s.EvaluatorCmd)
+	if err != nil {
+		panic(fmt.Sprintf("evaluator should have been verified as valid at this point: %v", err))
+	}
+
+	return func(optionName string, location []string) (string, error) {
+		command, err := tmpl.Execute(config.NewCommandTemplateData(*s, optionName, location))
 		if err != nil {
 			return "", err
 		}
 
-		cmdOutput, err := utils.ExecShellAndCaptureOutput(buf.String())
+		cmdOutput, err := s.Exec(command)
 		if err != nil {
 			return "", &option.AttributeEvaluationError{
 				Attribute:        optionName,
@@ -310,13 +539,21 @@ func constructEvaluatorFromScope(formatterCmd string, s *config.Scope) option.Ev
 
 		output := cmdOutput.Stdout
 
-		if formatterCmd != "" {
-			if formatted, err := option.FormatNixValue(formatterCmd, output); err == nil {
-				output = formatted
+		// JSON output is displayed in a structured form instead,
+		// so running a Nix formatter on it makes no sense.
+		if option.EvaluatorOutput(s.EvaluatorOutput) == option.EvaluatorOutputJSON {
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, []byte(strings.TrimSpace(output)), "", "  "); err != nil {
+				return "", &option.AttributeEvaluationError{
+					Attribute:        optionName,
+					EvaluationOutput: fmt.Sprintf("evaluator did not return valid JSON: %v", err),
+				}
 			}
+
+			return indented.String(), nil
 		}
 
-		value := strings.TrimSpace(output)
+		value := strings.TrimSpace(formatter.Format(output))
 
 		return value, nil
 	}
@@ -336,37 +573,30 @@ func commandMain(cmd *cobra.Command, opts *CmdOptions) error {
 		return nil
 	}
 
-	if !opts.NonInteractive {
-		scopes := make([]option.Scope, 0, len(cfg.Scopes))
-		for _, scope := range cfg.Scopes {
-			actualScope := constructScopeFromConfig(&scope, cfg.FormatterCmd)
-			scopes = append(scopes, actualScope)
-		}
+	selected, err := cfg.ResolveScope(opts.Scope)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
 
+	if !opts.NonInteractive {
 		return tui.OptionTUI(tui.OptionTUIArgs{
-			Scopes:            scopes,
-			SelectedScopeName: opts.Scope,
+			Scopes:            constructTUIScopes(cfg, selected),
+			SelectedScopeName: selected.Name,
 			MinScore:          cfg.MinScore,
 			DebounceTime:      cfg.DebounceTime,
 			InitialInput:      opts.OptionInput,
 			LogFileName:       "optnix",
+			ClipboardBackends: cfg.ClipboardBackends,
+			FormatterIndent:   cfg.FormatterIndent,
+			Reload:            reloadTUIScopes(cmd, opts),
+			Watch:             opts.Watch,
+			WatchFiles:        watchedFiles(cfg),
 		})
 	}
 
-	var scope *option.Scope
-	for _, s := range cfg.Scopes {
-		if opts.Scope == s.Name {
-			actualScope := constructScopeFromConfig(&s, cfg.FormatterCmd)
-			scope = &actualScope
-			break
-		}
-	}
-
-	if scope == nil {
-		err := fmt.Errorf("scope '%v' not found in configuration", opts.Scope)
-		log.Errorf("%v", err)
-		return err
-	}
+	actualScope := constructScopeFromConfig(&selected, constructValueFormatter(cfg))
+	scope := &actualScope
 
 	spinner := pin.New("Loading...",
 		pin.WithSpinnerColor(pin.ColorCyan),
@@ -400,22 +630,71 @@ func commandMain(cmd *cobra.Command, opts *CmdOptions) error {
 		var evalErr error
 
 		if scope.Evaluator != nil {
-			evaluatedValue, evalErr = scope.Evaluator(o.Name)
+			evaluatedValue, evalErr = scope.Evaluator(o.Name, o.Location)
 		} else {
 			evaluatedValue = "no evaluator configured for this scope"
 		}
 
+		// Definitions are only displayed in formats that include
+		// all information about an option.
+		var definitions []option.OptionDefinition
+		var definitionsErr error
+
+		showDefinitions := opts.Format == outputFormatPretty || opts.Format == string(option.OutputFormatJSON)
+		if scope.Definitions != nil && showDefinitions {
+			spinner.UpdateMessage("Finding option definitions...")
+			definitions, definitionsErr = scope.Definitions(o.Name, o.Location)
+		}
+
 		spinner.Stop()
 
-		if opts.JSON {
-			displayOptionJson(&o, &evaluatedValue)
-		} else if opts.ValueOnly {
-			fmt.Printf("%v\n", evaluatedValue)
-		} else {
+		// Values are displayed as Nix everywhere except for when
+		// only the raw value is requested.
+		displayedValue := evaluatedValue
+		if scope.EvaluatorOutput == option.EvaluatorOutputJSON && scope.Evaluator != nil && evalErr == nil {
+			if nixValue, err := option.JSONToNix(evaluatedValue); err == nil {
+				displayedValue = nixValue
+			}
+		}
+
+		switch opts.Format {
+		case outputFormatPretty:
 			fmt.Print(o.PrettyPrint(&option.ValuePrinterInput{
-				Value: evaluatedValue,
-				Err:   evalErr,
+				Value:          displayedValue,
+				Err:            evalErr,
+				Definitions:    definitions,
+				DefinitionsErr: definitionsErr,
 			}))
+		case outputFormatValue:
+			fmt.Printf("%v\n", evaluatedValue)
+		default:
+			format := option.OutputFormat(opts.Format)
+
+			// The JSON record has always included the evaluated value
+			// output, even on failure; other formats should only use
+			// real values, and fall back to defaults/examples otherwise.
+			value := &displayedValue
+			if format != option.OutputFormatJSON && (scope.Evaluator == nil || evalErr != nil) {
+				value = nil
+			}
+
+			var output string
+			var err error
+
+			if format == option.OutputFormatJSON {
+				if definitionsErr != nil {
+					log.Warn(option.DefinitionsErrorText(definitionsErr))
+				}
+				output, err = o.FormatJSON(value, definitions)
+			} else {
+				output, err = o.Format(format, value)
+			}
+			if err != nil {
+				log.Errorf("%v", err)
+				return err
+			}
+
+			fmt.Printf("%v\n", output)
 		}
 
 		return nil
@@ -433,7 +712,7 @@ func commandMain(cmd *cobra.Command, opts *CmdOptions) error {
 
 	fuzzySearchResults = utils.FilterMinimumScoreMatches(fuzzySearchResults, cfg.MinScore)
 
-	if opts.JSON {
+	if opts.Format == string(option.OutputFormatJSON) {
 		displayErrorJson(msg, fuzzySearchResults)
 		return err
 	}
@@ -451,46 +730,66 @@ func commandMain(cmd *cobra.Command, opts *CmdOptions) error {
 	return err
 }
 
-type optionJsonOutput struct {
-	Name         string   `json:"name"`
-	Description  string   `json:"description"`
-	Type         string   `json:"type"`
-	Value        *string  `json:"value"`
-	Default      string   `json:"default"`
-	Example      string   `json:"example"`
-	Location     []string `json:"loc"`
-	ReadOnly     bool     `json:"readOnly"`
-	Declarations []string `json:"declarations"`
+type errorJsonOutput struct {
+	Message        string   `json:"message"`
+	SimilarOptions []string `json:"similar_options"`
 }
 
-func displayOptionJson(o *option.NixosOption, evaluatedValue *string) {
-	defaultText := ""
-	if o.Default != nil {
-		defaultText = o.Default.Text
+func constructTUIScopes(cfg *config.Config, selected config.Scope) []option.Scope {
+	scopes := make([]option.Scope, 0, len(cfg.Scopes)+1)
+	for _, scope := range cfg.OrderedScopes() {
+		actualScope := constructScopeFromConfig(&scope, constructValueFormatter(cfg))
+		scopes = append(scopes, actualScope)
 	}
 
-	exampleText := ""
-	if o.Example != nil {
-		exampleText = o.Example.Text
+	// Scopes with parameter values are not part of the
+	// configuration itself.
+	if _, ok := cfg.Scopes[selected.Name]; !ok {
+		scopes = append(scopes, constructScopeFromConfig(&selected, constructValueFormatter(cfg)))
 	}
 
-	bytes, _ := json.MarshalIndent(optionJsonOutput{
-		Name:         o.Name,
-		Description:  o.Description,
-		Type:         o.Type,
-		Value:        evaluatedValue,
-		Default:      defaultText,
-		Example:      exampleText,
-		Location:     o.Location,
-		ReadOnly:     o.ReadOnly,
-		Declarations: o.Declarations,
-	}, "", "  ")
-	fmt.Printf("%v\n", string(bytes))
+	return scopes
 }
 
-type errorJsonOutput struct {
-	Message        string   `json:"message"`
-	SimilarOptions []string `json:"similar_options"`
+// Load configuration again in the same way as when starting, for
+// reloading it while the TUI is running. Nothing can be logged here,
+// since the TUI is using the terminal.
+func reloadTUIScopes(cmd *cobra.Command, opts *CmdOptions) tui.ReloadFunc {
+	return func(selectedScope string) (tui.ReloadResult, error) {
+		projectConfigLocations := config.ProjectConfigLocations()
+		configLocations := slices.Concat(defaultConfigLocations(cmd), projectConfigLocations, opts.Config)
+
+		cfg, err := config.ParseConfig(configLocations...)
+		if err != nil {
+			return tui.ReloadResult{}, fmt.Errorf("failed to parse config: %w", err)
+		}
+
+		if err := prepareConfig(cmd, cfg, projectConfigLocations); err != nil {
+			return tui.ReloadResult{}, err
+		}
+
+		selected, err := cfg.ResolveScope(selectedScope)
+		if err != nil {
+			return tui.ReloadResult{}, err
+		}
+
+		return tui.ReloadResult{
+			Scopes:     constructTUIScopes(cfg, selected),
+			WatchFiles: watchedFiles(cfg),
+		}, nil
+	}
+}
+
+// Files that cause a reload in watch mode when they change: all
+// loaded configuration files, and all options list files.
+func watchedFiles(cfg *config.Config) []string {
+	files := slices.Clone(cfg.Locations())
+
+	for _, s := range cfg.Scopes {
+		files = append(files, s.OptionsFiles()...)
+	}
+
+	return files
 }
 
 func displayErrorJson(msg string, matches fuzzy.Matches) {
@@ -0,0 +1,412 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdUtils "snare.dev/optnix/internal/cmd/utils"
+	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/diff"
+	"snare.dev/optnix/internal/logger"
+	"snare.dev/optnix/internal/snapshot"
+	"snare.dev/optnix/option"
+)
+
+func SnapshotCommand() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "snapshot",
+		Short: "Record option values and detect changes to them",
+		Long:  "Record evaluated option values to a local file, and compare them against current values later.",
+	}
+
+	cmd.AddCommand(SnapshotSaveCommand())
+	cmd.AddCommand(SnapshotDiffCommand())
+	cmd.AddCommand(SnapshotListCommand())
+
+	return &cmd
+}
+
+type SnapshotSaveOpts struct {
+	Scope string
+	Jobs  int
+
+	Name      string
+	Selectors []string
+}
+
+func SnapshotSaveCommand() *cobra.Command {
+	opts := SnapshotSaveOpts{}
+
+	cmd := cobra.Command{
+		Use:   "save -s [SCOPE] [NAME] [OPTION-OR-PREFIX...]",
+		Short: "Record evaluated option values",
+		Long:  "Evaluate options in a scope and record their values as a named snapshot. With no options or prefixes, all options in the scope are recorded.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "argument [NAME] is required",
+					Hint: `try running "optnix snapshot save [NAME] [OPTION-OR-PREFIX...]"`,
+				}
+			}
+
+			if err := snapshot.ValidateName(args[0]); err != nil {
+				return cmdUtils.ErrorWithHint{Msg: err.Error()}
+			}
+
+			if opts.Jobs < 1 {
+				return cmdUtils.ErrorWithHint{Msg: fmt.Sprintf("--jobs must be at least 1, got %v", opts.Jobs)}
+			}
+
+			opts.Name = args[0]
+			opts.Selectors = args[1:]
+
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.FromContext(cmd.Context())
+
+			if opts.Scope == "" {
+				opts.Scope = cfg.DefaultScope
+			}
+
+			if opts.Scope == "" {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "no scope was provided and no default scope is set in the configuration",
+					Hint: "either set a default configuration or specify one with -s",
+				}
+			}
+
+			return nil
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return completeSnapshots(cmd, args, toComplete)
+			}
+			return completeOptionsFromScope(&opts.Scope)(cmd, nil, toComplete)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := snapshotSaveMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Scope, "scope", "s", "", "Scope `name` to use")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "J", runtime.NumCPU(), "Number of options to evaluate in `parallel`")
+
+	_ = cmd.RegisterFlagCompletionFunc("scope", completeScopes)
+
+	return &cmd
+}
+
+type SnapshotDiffOpts struct {
+	Scope string
+	Jobs  int
+
+	Name string
+}
+
+func SnapshotDiffCommand() *cobra.Command {
+	opts := SnapshotDiffOpts{}
+
+	cmd := cobra.Command{
+		Use:   "diff [NAME]",
+		Short: "Compare current option values against a snapshot",
+		Long:  "Re-evaluate the options recorded in a snapshot, and report any values that have changed. Exits with a non-zero status if any changes are found.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return cmdUtils.ErrorWithHint{
+					Msg:  "argument [NAME] is required",
+					Hint: `try running "optnix snapshot diff [NAME]"`,
+				}
+			}
+
+			if opts.Jobs < 1 {
+				return cmdUtils.ErrorWithHint{Msg: fmt.Sprintf("--jobs must be at least 1, got %v", opts.Jobs)}
+			}
+
+			opts.Name = args[0]
+
+			return nil
+		},
+		ValidArgsFunction: completeSnapshots,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := snapshotDiffMain(cmd, &opts); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Scope, "scope", "s", "", "Scope `name` to compare against (default: the snapshot's scope)")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "J", runtime.NumCPU(), "Number of options to evaluate in `parallel`")
+
+	_ = cmd.RegisterFlagCompletionFunc("scope", completeScopes)
+
+	return &cmd
+}
+
+func SnapshotListCommand() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "list",
+		Short: "List saved snapshots",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := snapshotListMain(cmd); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	return &cmd
+}
+
+// Resolve option names and prefixes into a list of options. A
+// selector that exactly matches an option only selects that option;
+// otherwise, it selects all options under it.
+func selectOptions(options option.NixosOptionSource, selectors []string) ([]option.NixosOption, error) {
+	if len(selectors) == 0 {
+		return options, nil
+	}
+
+	seen := make(map[string]bool)
+	var selected []option.NixosOption
+
+	for _, sel := range selectors {
+		exact := false
+		for _, o := range options {
+			if o.Name == sel {
+				exact = true
+				break
+			}
+		}
+
+		matched := false
+		for _, o := range options {
+			if (exact && o.Name != sel) || !optionHasPrefix(o.Name, sel) {
+				continue
+			}
+
+			matched = true
+			if !seen[o.Name] {
+				seen[o.Name] = true
+				selected = append(selected, o)
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("no options match '%v'", sel)
+		}
+	}
+
+	return selected, nil
+}
+
+func snapshotSaveMain(cmd *cobra.Command, opts *SnapshotSaveOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := config.FromContext(cmd.Context())
+
+	scope, err := loadEvaluableScope(cfg, opts.Scope)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	spinner := newEvalSpinner()
+	cancelSpinner := spinner.Start(context.Background())
+	defer cancelSpinner()
+
+	spinner.UpdateMessage("Loading options...")
+
+	options, err := scope.Loader()
+	if err != nil {
+		spinner.Stop()
+		log.Errorf("%v", err)
+		return err
+	}
+
+	selected, err := selectOptions(options, opts.Selectors)
+	if err != nil {
+		spinner.Stop()
+		log.Errorf("%v", err)
+		return err
+	}
+
+	results := evaluateOptions(scope, selected, opts.Jobs, func(done int) {
+		spinner.UpdateMessage(fmt.Sprintf("Evaluating options (%d/%d)...", done, len(selected)))
+	})
+
+	spinner.Stop()
+
+	snap := snapshot.Snapshot{
+		Name:      opts.Name,
+		Scope:     opts.Scope,
+		CreatedAt: time.Now().UTC(),
+		Selectors: opts.Selectors,
+		Values:    make(map[string]snapshot.Value, len(selected)),
+	}
+
+	failed := 0
+	for i, r := range results {
+		v := snapshot.Value{Value: r.Value}
+		if r.Err != nil {
+			failed++
+			v = snapshot.Value{Error: r.Err.Error()}
+		}
+		snap.Values[selected[i].Name] = v
+	}
+
+	path, err := snapshot.Save(&snap)
+	if err != nil {
+		log.Errorf("failed to save snapshot: %v", err)
+		return err
+	}
+
+	log.Infof("saved %d options from scope '%v' to %v", len(selected), opts.Scope, path)
+	if failed > 0 {
+		log.Warnf("%d options could not be evaluated; their errors were recorded instead", failed)
+	}
+
+	return nil
+}
+
+var errSnapshotDrift = errors.New("option values have changed since the snapshot was taken")
+
+func snapshotDiffMain(cmd *cobra.Command, opts *SnapshotDiffOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := config.FromContext(cmd.Context())
+
+	snap, err := snapshot.Load(opts.Name)
+	if err != nil {
+		if errors.Is(err, snapshot.ErrNotFound) {
+			log.Errorf("snapshot '%v' does not exist", opts.Name)
+			log.Info("try running `optnix snapshot list` to see available snapshots")
+		} else {
+			log.Errorf("%v", err)
+		}
+		return err
+	}
+
+	scopeName := opts.Scope
+	if scopeName == "" {
+		scopeName = snap.Scope
+	}
+
+	scope, err := loadEvaluableScope(cfg, scopeName)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	spinner := newEvalSpinner()
+	cancelSpinner := spinner.Start(context.Background())
+	defer cancelSpinner()
+
+	spinner.UpdateMessage("Loading options...")
+
+	options, err := scope.Loader()
+	if err != nil {
+		spinner.Stop()
+		log.Errorf("%v", err)
+		return err
+	}
+
+	existing := make(map[string]option.NixosOption, len(options))
+	for _, o := range options {
+		existing[o.Name] = o
+	}
+
+	var selected []option.NixosOption
+	var removed []string
+	for _, name := range snap.OptionNames() {
+		if o, ok := existing[name]; ok {
+			selected = append(selected, o)
+		} else {
+			removed = append(removed, name)
+		}
+	}
+
+	results := evaluateOptions(scope, selected, opts.Jobs, func(done int) {
+		spinner.UpdateMessage(fmt.Sprintf("Evaluating options (%d/%d)...", done, len(selected)))
+	})
+
+	spinner.Stop()
+
+	changed := 0
+	for i, r := range results {
+		name := selected[i].Name
+		old := snap.Values[name]
+
+		current := snapshot.Value{Value: r.Value}
+		if r.Err != nil {
+			current = snapshot.Value{Error: r.Err.Error()}
+		}
+
+		if old.Error != "" || current.Error != "" {
+			if old.Error != "" && current.Error != "" {
+				continue
+			}
+
+			changed++
+			fmt.Printf("%v\n", diffHeaderColor.Sprint(name))
+			if current.Error != "" {
+				fmt.Printf("%v\n\n", diffDeleteColor.Sprintf("  now fails to evaluate: %v", current.Error))
+			} else {
+				fmt.Printf("%v\n\n", diffInsertColor.Sprint("  now evaluates successfully"))
+			}
+			continue
+		}
+
+		lines := diff.Lines(
+			option.ExpandNixValue(old.Value, cfg.FormatterIndent),
+			option.ExpandNixValue(current.Value, cfg.FormatterIndent),
+		)
+		if !diff.HasChanges(lines) {
+			continue
+		}
+
+		changed++
+		fmt.Printf("%v\n", diffHeaderColor.Sprint(name))
+		fmt.Println(formatDiff(lines, fmt.Sprintf("%v (snapshot)", opts.Name), fmt.Sprintf("%v (current)", scopeName)))
+	}
+
+	for _, name := range removed {
+		fmt.Printf("%v\n%v\n\n", diffHeaderColor.Sprint(name), diffDeleteColor.Sprint("  no longer exists"))
+	}
+
+	total := changed + len(removed)
+	if total == 0 {
+		log.Infof("no changes since snapshot '%v' was taken at %v", opts.Name, snap.CreatedAt.Local().Format(time.DateTime))
+		return nil
+	}
+
+	log.Warnf("%d of %d options changed since snapshot '%v' was taken at %v", total, len(snap.Values), opts.Name, snap.CreatedAt.Local().Format(time.DateTime))
+
+	return errSnapshotDrift
+}
+
+func snapshotListMain(cmd *cobra.Command) error {
+	log := logger.FromContext(cmd.Context())
+
+	names, err := snapshot.List()
+	if err != nil {
+		log.Errorf("failed to list snapshots: %v", err)
+		return err
+	}
+
+	for _, name := range names {
+		snap, err := snapshot.Load(name)
+		if err != nil {
+			log.Warnf("%v", err)
+			continue
+		}
+
+		fmt.Printf("%v\t%v\t%d options\t%v\n", name, snap.Scope, len(snap.Values), snap.CreatedAt.Local().Format(time.DateTime))
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cmdUtils "snare.dev/optnix/internal/cmd/utils"
+	"snare.dev/optnix/internal/config"
+	"snare.dev/optnix/internal/logger"
+)
+
+// Commands with this annotation can run even if project configuration
+// files that are not trusted are present.
+const annotationSkipTrustCheck = "optnix/skip-trust-check"
+
+type TrustOpts struct {
+	Files []string
+}
+
+func TrustCommand() *cobra.Command {
+	opts := TrustOpts{}
+
+	cmd := cobra.Command{
+		Use:   "trust [FILE...]",
+		Short: "Allow a project configuration file to run commands",
+		Long:  "Allow a project configuration file to run commands. Files are trusted by their contents, so any changes to them must be trusted again.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			opts.Files = args
+			return nil
+		},
+		Annotations: map[string]string{
+			annotationSkipTrustCheck: "true",
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := trustMain(cmd, &opts, true); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	return &cmd
+}
+
+func UntrustCommand() *cobra.Command {
+	opts := TrustOpts{}
+
+	cmd := cobra.Command{
+		Use:   "untrust [FILE...]",
+		Short: "Revoke trust for a project configuration file",
+		Args: func(cmd *cobra.Command, args []string) error {
+			opts.Files = args
+			return nil
+		},
+		Annotations: map[string]string{
+			annotationSkipTrustCheck: "true",
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := trustMain(cmd, &opts, false); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	return &cmd
+}
+
+func trustMain(cmd *cobra.Command, opts *TrustOpts, trust bool) error {
+	log := logger.FromContext(cmd.Context())
+
+	files := opts.Files
+	if len(files) == 0 {
+		files = config.ProjectConfigLocations()
+
+		if len(files) == 0 {
+			err := fmt.Errorf("no project configuration files found")
+			log.Errorf("%v", err)
+			log.Infof("looked for %v in this directory and its parents", strings.Join(config.ProjectConfigNames, " or "))
+			return err
+		}
+	}
+
+	store, err := config.LoadTrustStore()
+	if err != nil {
+		log.Errorf("failed to load trust store: %v", err)
+		return err
+	}
+
+	for _, file := range files {
+		if trust {
+			path, err := store.Trust(file)
+			if err != nil {
+				log.Errorf("failed to trust %v: %v", file, err)
+				return err
+			}
+			log.Infof("trusted %v", path)
+		} else {
+			wasTrusted, err := store.Untrust(file)
+			if err != nil {
+				log.Errorf("failed to untrust %v: %v", file, err)
+				return err
+			}
+
+			if wasTrusted {
+				log.Infof("no longer trusting %v", file)
+			} else {
+				log.Warnf("%v was not trusted", file)
+			}
+		}
+	}
+
+	if err := store.Save(); err != nil {
+		log.Errorf("failed to save trust store: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Ensure that all project configuration files that set commands
+// to run have been trusted by the user.
+func checkProjectConfigTrust(cfg *config.Config, locations []string) error {
+	var store *config.TrustStore
+
+	for _, loc := range locations {
+		keys := cfg.CommandKeysFrom(loc)
+		if len(keys) == 0 {
+			continue
+		}
+
+		if store == nil {
+			var err error
+			store, err = config.LoadTrustStore()
+			if err != nil {
+				return fmt.Errorf("failed to load trust store: %w", err)
+			}
+		}
+
+		status, err := store.Status(loc)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case config.TrustStatusTrusted:
+			continue
+		case config.TrustStatusChanged:
+			return cmdUtils.ErrorWithHint{
+				Msg:  fmt.Sprintf("project configuration %v has changed since it was trusted, and it sets commands to run, fetches options from a URL, or reads environment variables (%v)", loc, strings.Join(keys, ", ")),
+				Hint: fmt.Sprintf("review the changes, then run `optnix trust %v` to allow it again", loc),
+			}
+		default:
+			return cmdUtils.ErrorWithHint{
+				Msg:  fmt.Sprintf("project configuration %v is not trusted, and it sets commands to run, fetches options from a URL, or reads environment variables (%v)", loc, strings.Join(keys, ", ")),
+				Hint: fmt.Sprintf("review its contents, then run `optnix trust %v` to allow it", loc),
+			}
+		}
+	}
+
+	return nil
+}
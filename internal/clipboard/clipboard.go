@@ -0,0 +1,149 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+type Backend string
+
+const (
+	// Use the system clipboard through external utilities
+	// such as `xclip`, `xsel`, `wl-copy`, or `pbcopy`.
+	BackendSystem Backend = "system"
+	// Write an OSC 52 escape sequence to the terminal, which lets
+	// the terminal emulator set the clipboard itself. This works
+	// over SSH and inside of tmux.
+	BackendOSC52 Backend = "osc52"
+)
+
+var DefaultBackends = []Backend{BackendSystem, BackendOSC52}
+
+var AvailableBackends = []Backend{BackendSystem, BackendOSC52}
+
+func (b Backend) Valid() bool {
+	for _, v := range AvailableBackends {
+		if b == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Human-readable name of a backend, for use in notifications.
+func (b Backend) DisplayName() string {
+	switch b {
+	case BackendSystem:
+		return "system clipboard"
+	case BackendOSC52:
+		return "OSC 52"
+	default:
+		return string(b)
+	}
+}
+
+func ParseBackends(names []string) ([]Backend, error) {
+	backends := make([]Backend, 0, len(names))
+
+	for _, name := range names {
+		b := Backend(name)
+		if !b.Valid() {
+			return nil, fmt.Errorf("unknown clipboard backend '%v'", name)
+		}
+		backends = append(backends, b)
+	}
+
+	return backends, nil
+}
+
+// Names of backends, as they are written in configuration.
+func BackendNames(backends []Backend) []string {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = string(b)
+	}
+	return names
+}
+
+var ErrNoBackends = errors.New("no clipboard backends configured")
+
+type CopyResult struct {
+	// The backend that was used
+	Backend Backend
+	// Escape sequence that still has to be written to the terminal
+	// for the copy to happen. Terminals do not reply to these, so
+	// there is no way to know if the copy actually worked.
+	Sequence string
+}
+
+// Copy the given value using the first backend in the chain that
+// succeeds, and return the backend that was used.
+//
+// The OSC 52 backend does not write to the terminal itself, since
+// that would interfere with anything else drawing to it; the caller
+// must write the sequence in the result instead.
+//
+// If all backends fail, the returned error will contain the
+// errors for each individual backend that was attempted, on
+// a single line so that it can be displayed in a status bar.
+func Copy(backends []Backend, value string) (CopyResult, error) {
+	if len(backends) == 0 {
+		return CopyResult{}, ErrNoBackends
+	}
+
+	var errs []string
+
+	for _, b := range backends {
+		var err error
+
+		switch b {
+		case BackendSystem:
+			err = copySystem(value)
+		case BackendOSC52:
+			return CopyResult{
+				Backend:  b,
+				Sequence: osc52Sequence(value, os.Getenv("TMUX") != ""),
+			}, nil
+		default:
+			err = fmt.Errorf("unknown clipboard backend")
+		}
+
+		if err == nil {
+			return CopyResult{Backend: b}, nil
+		}
+
+		errs = append(errs, fmt.Sprintf("%v: %v", b, err))
+	}
+
+	return CopyResult{}, errors.New(strings.Join(errs, "; "))
+}
+
+func copySystem(value string) error {
+	if clipboard.Unsupported {
+		return errors.New("no clipboard utilities available")
+	}
+
+	return clipboard.WriteAll(value)
+}
+
+// Construct an OSC 52 sequence that sets the system clipboard ("c")
+// selection to the given value.
+//
+// tmux swallows unknown escape sequences unless they are wrapped in
+// a DCS passthrough sequence, with each inner ESC doubled. This also
+// requires `allow-passthrough` to be enabled in newer tmux versions.
+func osc52Sequence(value string, tmux bool) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(value))
+	seq := "\x1b]52;c;" + encoded + "\x07"
+
+	if tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+
+	return seq
+}
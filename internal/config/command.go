@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// A command to run for a scope. In the configuration, this is either
+// a string that is run with `/bin/sh -c`, or a list of arguments
+// that is run directly without a shell.
+type Command struct {
+	Shell string
+	Argv  []string
+}
+
+func (c Command) IsEmpty() bool {
+	return c.Shell == "" && len(c.Argv) == 0
+}
+
+// Whether or not this command is run directly, without a shell.
+func (c Command) IsArgv() bool {
+	return len(c.Argv) > 0
+}
+
+// A human-readable representation of the command, as it would be
+// typed into a shell.
+func (c Command) String() string {
+	if !c.IsArgv() {
+		return c.Shell
+	}
+
+	quoted := make([]string, len(c.Argv))
+	for i, arg := range c.Argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var commandType = reflect.TypeOf(Command{})
+
+// Decode strings and lists of strings in the configuration into
+// Command values.
+func commandDecodeHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if to != commandType {
+		return data, nil
+	}
+
+	switch v := data.(type) {
+	case Command:
+		return v, nil
+	case string:
+		return Command{Shell: v}, nil
+	case []any:
+		argv := make([]string, len(v))
+		for i, arg := range v {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("command arguments must be strings, got %v at index %d", arg, i)
+			}
+			argv[i] = s
+		}
+
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("command argument list must not be empty")
+		}
+
+		return Command{Argv: argv}, nil
+	case []string:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("command argument list must not be empty")
+		}
+
+		return Command{Argv: slices.Clone(v)}, nil
+	}
+
+	return nil, fmt.Errorf("expected a command string or list of arguments, got %T", data)
+}
+
+// A command whose shell string or arguments are templates.
+type CommandTemplate struct {
+	shell *template.Template
+	argv  []*template.Template
+}
+
+// Parse a command as a template with the functions available to
+// all command templates. In argument list form, each argument is
+// a separate template.
+func ParseCommandTemplate(name string, cmd Command) (*CommandTemplate, error) {
+	if !cmd.IsArgv() {
+		tmpl, err := parseTemplate(name, cmd.Shell)
+		if err != nil {
+			return nil, err
+		}
+		return &CommandTemplate{shell: tmpl}, nil
+	}
+
+	t := &CommandTemplate{argv: make([]*template.Template, len(cmd.Argv))}
+	for i, arg := range cmd.Argv {
+		tmpl, err := parseTemplate(fmt.Sprintf("%v[%d]", name, i), arg)
+		if err != nil {
+			return nil, err
+		}
+		t.argv[i] = tmpl
+	}
+
+	return t, nil
+}
+
+// Render a command template. Rendered arguments are never split or
+// otherwise interpreted, so they do not need to be quoted.
+func (t *CommandTemplate) Execute(data CommandTemplateData) (Command, error) {
+	if t.shell != nil {
+		shell, err := executeTemplate(t.shell, data)
+		if err != nil {
+			return Command{}, err
+		}
+		return Command{Shell: shell}, nil
+	}
+
+	argv := make([]string, len(t.argv))
+	for i, tmpl := range t.argv {
+		arg, err := executeTemplate(tmpl, data)
+		if err != nil {
+			return Command{}, err
+		}
+		argv[i] = arg
+	}
+
+	return Command{Argv: argv}, nil
+}
@@ -1,16 +1,16 @@
 package config
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
 
 	"github.com/fatih/color"
-	"github.com/knadh/koanf/parsers/toml/v2"
-	"github.com/knadh/koanf/providers/file"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/knadh/koanf/v2"
+	"snare.dev/optnix/internal/clipboard"
+	"snare.dev/optnix/option"
 )
 
 type Config struct {
@@ -19,68 +19,80 @@ type Config struct {
 	DefaultScope string `koanf:"default_scope"`
 	FormatterCmd string `koanf:"formatter_cmd"`
 
+	FormatterIndent int `koanf:"formatter_indent"`
+	FormatterWidth  int `koanf:"formatter_width"`
+
+	ClipboardBackends []string `koanf:"clipboard_backends"`
+
+	// Other configuration files to load after this one. This is only
+	// used while loading files, and is always empty afterwards.
+	Include []string `koanf:"include"`
+
 	Scopes map[string]Scope `koanf:"scopes"`
 
 	// Origins of a set configuration value, used for tracking
 	// when/where the most recent value of a field was set
 	// for debugging configurations.
 	fieldOrigins map[string]string
+	// Flattened values from all loaded configuration files
+	values map[string]any
+	// Configuration files that were loaded, in order
+	locations []string
+	// Scopes that are only used as a base for other scopes
+	baseScopes map[string]Scope
+	// Files included by each configuration file
+	includes map[string][]string
+	// Keys that refer to environment variables, keyed by the file
+	// that set them
+	envKeys map[string][]string
 }
 
 func NewConfig() *Config {
 	return &Config{
 		MinScore:     1,
 		DebounceTime: 25,
-		FormatterCmd: "nixfmt",
+		FormatterCmd: "",
+
+		FormatterIndent: option.DefaultFormatterIndent,
+		FormatterWidth:  option.DefaultFormatterWidth,
+
+		ClipboardBackends: clipboard.BackendNames(clipboard.DefaultBackends),
 
 		Scopes: make(map[string]Scope),
 	}
 }
 
+// Parse and merge configuration files, in order of lowest to highest
+// priority. Files that do not exist are skipped.
 func ParseConfig(location ...string) (*Config, error) {
-	k := koanf.New(".")
-
-	fieldOrigins := make(map[string]string)
+	l := newConfigLoader()
 
 	for _, loc := range location {
-		if _, err := os.Stat(loc); err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				continue
-			}
-
-			return nil, err
-		}
-
-		fileK := koanf.New(".")
-
-		err := fileK.Load(file.Provider(loc), toml.Parser())
-		if err != nil {
+		if err := l.load(loc); err != nil {
 			return nil, err
 		}
-
-		for _, key := range fileK.Keys() {
-			fieldOrigins[key] = loc
-		}
-
-		// Also load incomplete scope keys into the field origins, since
-		// scopes without proper definitions can technically exist.
-		if scopesMap, ok := fileK.Get("scopes").(map[string]interface{}); ok {
-			for scopeName := range scopesMap {
-				scopeKey := fmt.Sprintf("scopes.%s", scopeName)
-				fieldOrigins[scopeKey] = loc
-			}
-		}
-
-		if err := k.Merge(fileK); err != nil {
-			return nil, err
-		}
-
 	}
 
-	cfg := NewConfig()
-	cfg.fieldOrigins = fieldOrigins
+	k := l.k
 
-	if err := k.Unmarshal("", cfg); err != nil {
+	cfg := NewConfig()
+	cfg.fieldOrigins = l.fieldOrigins
+	cfg.values = k.All()
+	cfg.locations = l.loaded
+	cfg.includes = l.includes
+	cfg.envKeys = l.envKeys
+
+	err := k.UnmarshalWithConf("", cfg, koanf.UnmarshalConf{
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				commandDecodeHook,
+			),
+			Result:           cfg,
+			WeaklyTypedInput: true,
+		},
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -89,10 +101,59 @@ func ParseConfig(location ...string) (*Config, error) {
 		cfg.Scopes[name] = scope
 	}
 
+	cfg.resolveScopeInheritance()
+
 	return cfg, nil
 }
 
-var optionTemplateRegex = regexp.MustCompile(`{{\s*-?\s*\.Option\b[^}]*}}`)
+// Scope settings that are paths, which are resolved relative to
+// the configuration file that they are set in.
+var scopePathKeys = []string{"options-list-file", "cwd"}
+
+func resolveRelativePaths(k *koanf.Koanf, dir string) error {
+	scopesMap, ok := k.Get("scopes").(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	for scopeName := range scopesMap {
+		for _, pathKey := range scopePathKeys {
+			key := fmt.Sprintf("scopes.%s.%s", scopeName, pathKey)
+
+			path, ok := k.Get(key).(string)
+			if !ok || !isRelativePath(path) {
+				continue
+			}
+
+			if err := k.Set(key, filepath.Join(dir, path)); err != nil {
+				return err
+			}
+		}
+
+		if err := resolveSourcePaths(k, fmt.Sprintf("scopes.%s.sources", scopeName), dir); err != nil {
+			return err
+		}
+
+		// Flakes can also be references such as `github:owner/repo`,
+		// so they are only resolved if they look like a relative path.
+		flakeKey := fmt.Sprintf("scopes.%s.flake", scopeName)
+		if ref, ok := k.Get(flakeKey).(string); ok && isRelativeFlakePath(ref) {
+			if err := k.Set(flakeKey, filepath.Join(dir, ref)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func isRelativePath(path string) bool {
+	return path != "" && path != StdinPath && !filepath.IsAbs(path)
+}
+
+func isRelativeFlakePath(ref string) bool {
+	return ref == "." || ref == ".." || strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "../")
+}
 
 type ValidationError struct {
 	Msg    string
@@ -108,8 +169,71 @@ func (e ValidationError) Error() string {
 }
 
 func (c *Config) Validate() error {
+	if err := c.validateKeys(); err != nil {
+		return err
+	}
+
 	for s, v := range c.Scopes {
-		if v.OptionsListCmd == "" && v.OptionsListFile == "" {
+		if err := c.validateScopeExtends(s, v); err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateScopeAliases(); err != nil {
+		return err
+	}
+
+	for s, v := range c.Scopes {
+		if v.Flake != "" {
+			if err := c.validateFlakeScope(s); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := c.validateScopeParams(s, v); err != nil {
+			return err
+		}
+
+		if len(v.Params) > 0 {
+			if _, err := ParseCommandTemplate("options-list-cmd", v.OptionsListCmd); err != nil {
+				return ValidationError{
+					Msg:    fmt.Sprintf("invalid options-list-cmd template for scope '%v': %v", s, err),
+					Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.options-list-cmd", s)),
+				}
+			}
+		}
+
+		if err := c.validateScopeSources(s, v); err != nil {
+			return err
+		}
+
+		if v.OptionsListURL != "" {
+			if err := validateOptionsURL(v.OptionsListURL); err != nil {
+				return ValidationError{
+					Msg:    fmt.Sprintf("invalid options-list-url '%v' for scope '%v': %v", v.OptionsListURL, s, err),
+					Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.options-list-url", s)),
+				}
+			}
+
+			// Credentials are only sent to the URL that was set next to
+			// them, so that another file cannot send them elsewhere.
+			urlOrigin := c.FieldOrigin(fmt.Sprintf("scopes.%v.options-list-url", s))
+			authOrigin := c.FieldOrigin(fmt.Sprintf("scopes.%v.options-list-url-auth-env", s))
+			if v.OptionsListURLAuthEnv != "" && urlOrigin != authOrigin {
+				return ValidationError{
+					Msg:    fmt.Sprintf("options-list-url-auth-env of scope '%v' must be set in the same file as its options-list-url", s),
+					Origin: urlOrigin,
+				}
+			}
+		} else if v.OptionsListURLAuthEnv != "" {
+			return ValidationError{
+				Msg:    fmt.Sprintf("scope '%v' sets options-list-url-auth-env without options-list-url", s),
+				Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.options-list-url-auth-env", s)),
+			}
+		}
+
+		if v.OptionsListCmd.IsEmpty() && v.OptionsListFile == "" && v.OptionsListURL == "" && len(v.Sources) == 0 {
 			return ValidationError{
 				Msg:    fmt.Sprintf("no option list source defined for scope '%v'", s),
 				Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v", s)),
@@ -119,13 +243,20 @@ func (c *Config) Validate() error {
 
 	if c.DefaultScope != "" {
 		foundScope := false
-		for n := range c.Scopes {
-			if n == c.DefaultScope {
+		for n, v := range c.Scopes {
+			// Scopes generated from flakes are only known once they
+			// have been expanded.
+			if n == c.DefaultScope || (v.Flake != "" && strings.HasPrefix(c.DefaultScope, n+"/")) {
 				foundScope = true
 				break
 			}
 		}
 
+		if !foundScope {
+			_, err := c.ResolveScope(c.DefaultScope)
+			foundScope = err == nil
+		}
+
 		if !foundScope {
 			return ValidationError{
 				Msg:    fmt.Sprintf("default scope '%v' not found", c.DefaultScope),
@@ -134,23 +265,54 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.FormatterIndent < 0 {
+		return ValidationError{
+			Msg:    fmt.Sprintf("formatter indent must not be negative, got %v", c.FormatterIndent),
+			Origin: c.FieldOrigin("formatter_indent"),
+		}
+	}
+
+	if c.FormatterWidth <= 0 {
+		return ValidationError{
+			Msg:    fmt.Sprintf("formatter width must be positive, got %v", c.FormatterWidth),
+			Origin: c.FieldOrigin("formatter_width"),
+		}
+	}
+
+	if _, err := clipboard.ParseBackends(c.ClipboardBackends); err != nil {
+		return ValidationError{
+			Msg:    err.Error(),
+			Origin: c.FieldOrigin("clipboard_backends"),
+		}
+	}
+
 	for s, v := range c.Scopes {
-		if v.EvaluatorCmd == "" {
-			continue
+		switch option.EvaluatorOutput(v.EvaluatorOutput) {
+		case "", option.EvaluatorOutputNix, option.EvaluatorOutputJSON:
+		default:
+			return ValidationError{
+				Msg:    fmt.Sprintf("invalid evaluator output '%v' for scope '%v', expected one of: nix, json", v.EvaluatorOutput, s),
+				Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.evaluator-output", s)),
+			}
 		}
 
-		matches := optionTemplateRegex.FindAllString(v.EvaluatorCmd, -1)
-		if len(matches) != 1 {
-			origin := c.FieldOrigin(fmt.Sprintf("scopes.%v.evaluator", s))
-			if len(matches) == 0 {
-				return ValidationError{
-					Msg:    fmt.Sprintf("evaluator for scope '%v' does not contain the placeholder {{ .Option }}", s),
-					Origin: origin,
-				}
-			} else {
+		if err := c.validateOptionTemplate(v, "evaluator", v.EvaluatorCmd); err != nil {
+			return err
+		}
+
+		if err := c.validateOptionTemplate(v, "definitions", v.DefinitionsCmd); err != nil {
+			return err
+		}
+
+		if err := c.validateBatchTemplate(v); err != nil {
+			return err
+		}
+
+		if v.Cwd != "" {
+			if info, err := os.Stat(v.Cwd); err != nil || !info.IsDir() {
 				return ValidationError{
-					Msg:    fmt.Sprintf("multiple instances of {{ .Option }} placeholder in evaluator for scope '%v'", s),
-					Origin: origin,
+					Msg:    fmt.Sprintf("working directory '%v' for scope '%v' is not a directory", v.Cwd, s),
+					Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.cwd", s)),
 				}
 			}
 		}
@@ -159,6 +321,88 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// Ensure that a command template for a scope is valid, and that it
+// refers to the option being evaluated through either {{ .Option }}
+// or {{ .Location }}, if it is set.
+func (c *Config) validateOptionTemplate(scope Scope, key string, cmd Command) error {
+	if cmd.IsEmpty() {
+		return nil
+	}
+
+	origin := c.FieldOrigin(fmt.Sprintf("scopes.%v.%v", scope.Name, key))
+
+	tmpl, err := ParseCommandTemplate(key, cmd)
+	if err != nil {
+		return ValidationError{
+			Msg:    fmt.Sprintf("invalid %v template for scope '%v': %v", key, scope.Name, err),
+			Origin: origin,
+		}
+	}
+
+	// Render the template for two different options; if the results
+	// are the same, then the option is never used.
+	var rendered []string
+	for _, name := range []string{"a.b", `c."d.e"`} {
+		output, err := tmpl.Execute(NewCommandTemplateData(scope, name, nil))
+		if err != nil {
+			return ValidationError{
+				Msg:    fmt.Sprintf("invalid %v template for scope '%v': %v", key, scope.Name, err),
+				Origin: origin,
+			}
+		}
+		rendered = append(rendered, output.String())
+	}
+
+	if rendered[0] == rendered[1] {
+		return ValidationError{
+			Msg:    fmt.Sprintf("%v for scope '%v' does not use the option name through {{ .Option }} or {{ .Location }}", key, scope.Name),
+			Origin: origin,
+		}
+	}
+
+	return nil
+}
+
+// Ensure that the batch evaluator template of a scope is valid, and
+// that it refers to the options being evaluated through {{ .Options }}
+// or {{ .Locations }}, if it is set.
+func (c *Config) validateBatchTemplate(scope Scope) error {
+	if scope.BatchEvaluatorCmd.IsEmpty() {
+		return nil
+	}
+
+	origin := c.FieldOrigin(fmt.Sprintf("scopes.%v.batch-evaluator", scope.Name))
+
+	tmpl, err := ParseCommandTemplate("batch-evaluator", scope.BatchEvaluatorCmd)
+	if err != nil {
+		return ValidationError{
+			Msg:    fmt.Sprintf("invalid batch-evaluator template for scope '%v': %v", scope.Name, err),
+			Origin: origin,
+		}
+	}
+
+	var rendered []string
+	for _, names := range [][]string{{"a.b"}, {`c."d.e"`, "f"}} {
+		output, err := tmpl.Execute(NewBatchCommandTemplateData(scope, names, nil))
+		if err != nil {
+			return ValidationError{
+				Msg:    fmt.Sprintf("invalid batch-evaluator template for scope '%v': %v", scope.Name, err),
+				Origin: origin,
+			}
+		}
+		rendered = append(rendered, output.String())
+	}
+
+	if rendered[0] == rendered[1] {
+		return ValidationError{
+			Msg:    fmt.Sprintf("batch-evaluator for scope '%v' does not use the option names through {{ .Options }} or {{ .Locations }}", scope.Name),
+			Origin: origin,
+		}
+	}
+
+	return nil
+}
+
 func (c *Config) FieldOrigin(key string) string {
 	if c.fieldOrigins == nil {
 		return ""
@@ -170,9 +414,56 @@ func (c *Config) FieldOrigin(key string) string {
 var DefaultConfigLocations = []string{
 	"/etc/optnix/config.toml",
 	// User config path filled in by init(), depending on `XDG_CONFIG_HOME` presence
-	// optnix.toml in the current directory, if it exists
 }
 
+// Names of project configuration files, which are searched for in
+// the current directory and its parents.
+var ProjectConfigNames = []string{"optnix.toml", ".optnix.toml"}
+
+// Find project configuration files, which are loaded after the
+// default locations. These can come from anywhere (i.e. a cloned
+// repository), so they must be trusted before any commands in them
+// can run.
+//
+// The search starts in the current directory, and stops at the
+// first directory that contains a project configuration file, or
+// at the root of the repository or filesystem. The returned paths
+// are absolute.
+func ProjectConfigLocations() []string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	for {
+		var found []string
+		for _, name := range ProjectConfigNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				found = append(found, path)
+			}
+		}
+
+		if len(found) > 0 {
+			return found
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Path to the configuration file for the current user, filled in by
+// init(); empty if it cannot be determined.
+var UserConfigLocation string
+
 func init() {
 	var homeDirPath string
 	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
@@ -182,8 +473,7 @@ func init() {
 	}
 
 	if homeDirPath != "" {
+		UserConfigLocation = homeDirPath
 		DefaultConfigLocations = append(DefaultConfigLocations, homeDirPath)
 	}
-
-	DefaultConfigLocations = append(DefaultConfigLocations, "optnix.toml")
 }
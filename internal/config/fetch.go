@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"snare.dev/optnix/option"
+)
+
+// Location of options lists fetched over HTTP, depending on
+// `XDG_CACHE_HOME` presence.
+func OptionsCacheDir() (string, error) {
+	if xdgCacheHome := os.Getenv("XDG_CACHE_HOME"); xdgCacheHome != "" {
+		return filepath.Join(xdgCacheHome, "optnix", "options"), nil
+	}
+
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("neither $XDG_CACHE_HOME nor $HOME are set")
+	}
+
+	return filepath.Join(home, ".cache", "optnix", "options"), nil
+}
+
+// Validators for a cached options list, which are sent along with
+// the next request so that the server can reply that it has not
+// changed.
+type optionsCacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+type optionsCache struct {
+	dataPath string
+	metaPath string
+}
+
+func newOptionsCache(rawURL string) (*optionsCache, error) {
+	dir, err := OptionsCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+	key := hex.EncodeToString(sum[:])
+
+	return &optionsCache{
+		dataPath: filepath.Join(dir, key+".json"),
+		metaPath: filepath.Join(dir, key+".meta.json"),
+	}, nil
+}
+
+func (c *optionsCache) meta() (optionsCacheMeta, bool) {
+	var meta optionsCacheMeta
+
+	data, err := os.ReadFile(c.metaPath)
+	if err != nil {
+		return meta, false
+	}
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, false
+	}
+
+	if _, err := os.Stat(c.dataPath); err != nil {
+		return meta, false
+	}
+
+	return meta, true
+}
+
+func (c *optionsCache) save(data []byte, meta optionsCacheMeta) error {
+	if err := os.MkdirAll(filepath.Dir(c.dataPath), 0o755); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(c.dataPath, data); err != nil {
+		return err
+	}
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.metaPath, append(metaData, '\n'))
+}
+
+// Write a file through a temporary file, so that other instances
+// never read a partially written cache.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Fetch an options list over HTTP, revalidating the cached copy of
+// it (if any) with the server. If the server cannot be reached, the
+// cached copy is used even if it is outdated.
+func fetchOptionsList(rawURL string, authEnv string) (option.NixosOptionSource, error) {
+	cache, err := newOptionsCache(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, cached := cache.meta()
+
+	stale := func(err error) (option.NixosOptionSource, error) {
+		if !cached {
+			return nil, err
+		}
+		return loadOptionsFile(cache.dataPath)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "optnix")
+	if cached {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	// Without credentials (i.e. before logging in), the cached copy
+	// is still better than nothing.
+	if authEnv != "" {
+		value := os.Getenv(authEnv)
+		if value == "" {
+			return stale(fmt.Errorf("environment variable '%v' for the Authorization header is not set", authEnv))
+		}
+		req.Header.Set("Authorization", value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return stale(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		return loadOptionsFile(cache.dataPath)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return stale(fmt.Errorf("server responded with %v", resp.Status))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("server responded with %v", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return stale(err)
+	}
+
+	options, err := option.LoadOptions(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid options list: %v", err)
+	}
+
+	// Failing to cache the list should not stop it from being used.
+	_ = cache.save(data, optionsCacheMeta{
+		URL:          rawURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+
+	return options, nil
+}
+
+func validateOptionsURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("only http and https URLs are supported")
+	}
+
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"snare.dev/optnix/option"
+)
+
+// A type of module system configuration that flakes can provide.
+type FlakeConfigurationKind struct {
+	// Human-readable name of the module system
+	Name string
+	// Flake output attribute that contains configurations
+	Attr string
+	// Name to use for generated scopes
+	ScopeName string
+}
+
+var FlakeConfigurationKinds = []FlakeConfigurationKind{
+	{Name: "NixOS", Attr: "nixosConfigurations", ScopeName: "nixos"},
+	{Name: "home-manager", Attr: "homeConfigurations", ScopeName: "home-manager"},
+	{Name: "nix-darwin", Attr: "darwinConfigurations", ScopeName: "nix-darwin"},
+}
+
+// Nix function that creates an options list from a configuration
+// that has `options` and `pkgs` attributes, like the ones created
+// by `lib.nixosSystem`.
+const optionsListApplyExpr = "input: builtins.filter (v: v.visible && !v.internal) (input.pkgs.lib.optionAttrSetToDocList input.options)"
+
+// Nix function that finds the values of the options given to a batch
+// evaluator template in a configuration's `config` attribute set.
+const batchEvaluatorApplyExpr = "config: map (loc: builtins.foldl' (value: name: builtins.getAttr name value) config loc) (builtins.fromJSON {{ json (json .Locations) }})"
+
+// Find a flake in the given directory, returning its absolute path.
+func FindFlake(dir string) (string, bool) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+
+	if info, err := os.Stat(filepath.Join(absDir, "flake.nix")); err != nil || info.IsDir() {
+		return "", false
+	}
+
+	return absDir, true
+}
+
+// Construct a flake output reference for an attribute path.
+func FlakeRef(flake string, attrPath ...string) string {
+	return flake + "#" + option.FormatAttrPath(attrPath)
+}
+
+// Default command for discovering configurations in a flake, which
+// prints the names of configurations of each kind.
+var defaultFlakeShowCmd = Command{
+	Argv: []string{
+		"nix", "eval", "--json", "--impure", "--expr",
+		"let flake = builtins.getFlake {{ json .Flake }}; in " +
+			"builtins.mapAttrs (_: builtins.attrNames) " +
+			"(builtins.intersectAttrs { nixosConfigurations = null; homeConfigurations = null; darwinConfigurations = null; } flake)",
+	},
+}
+
+// Discover the names of configurations in a scope's flake, keyed by
+// the flake output attribute they are in.
+//
+// The output of `nix flake show --json` is also accepted, which
+// allows using it as `flake-show-cmd`.
+func (s Scope) FlakeConfigurations() (map[string][]string, error) {
+	cmd := s.FlakeShowCmd
+	if cmd.IsEmpty() {
+		cmd = defaultFlakeShowCmd
+	}
+
+	tmpl, err := ParseCommandTemplate("flake-show-cmd", cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := tmpl.Execute(NewCommandTemplateData(s, "", nil))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.Exec(command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %v: %v", command, strings.TrimSpace(output.Stderr))
+	}
+
+	var outputs map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(output.Stdout), &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse flake configurations: %v", err)
+	}
+
+	configurations := make(map[string][]string)
+
+	for _, kind := range FlakeConfigurationKinds {
+		raw, ok := outputs[kind.Attr]
+		if !ok {
+			continue
+		}
+
+		names, err := parseFlakeConfigurationNames(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %v", kind.Attr, err)
+		}
+
+		configurations[kind.Attr] = names
+	}
+
+	return configurations, nil
+}
+
+func parseFlakeConfigurationNames(raw json.RawMessage) ([]string, error) {
+	var names []string
+	if err := json.Unmarshal(raw, &names); err == nil {
+		return names, nil
+	}
+
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &attrs); err != nil {
+		return nil, fmt.Errorf("expected a list of names or an attribute set")
+	}
+
+	// `nix flake show` does not list outputs it does not know about
+	// (such as `homeConfigurations`), and only reports their type.
+	if typ, ok := attrs["type"]; ok && len(attrs) == 1 {
+		var s string
+		if json.Unmarshal(typ, &s) == nil {
+			return nil, nil
+		}
+	}
+
+	names = slices.Sorted(maps.Keys(attrs))
+
+	return names, nil
+}
+
+// Replace scopes that set `flake` with a scope for each configuration
+// in the flake, named `<scope>/<configuration>`. Other settings of
+// the scope, such as `env`, are inherited by the generated scopes.
+//
+// This runs commands, so it must only be done for trusted
+// configurations.
+func (c *Config) ExpandFlakeScopes() error {
+	var flakeScopes []string
+	for name, s := range c.Scopes {
+		if s.Flake != "" {
+			flakeScopes = append(flakeScopes, name)
+		}
+	}
+	slices.Sort(flakeScopes)
+
+	for _, name := range flakeScopes {
+		if err := c.validateFlakeScope(name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range flakeScopes {
+		s := c.Scopes[name]
+
+		configurations, err := s.FlakeConfigurations()
+		if err != nil {
+			return fmt.Errorf("failed to discover configurations for scope '%v': %w", name, err)
+		}
+
+		delete(c.Scopes, name)
+
+		origin := c.FieldOrigin(fmt.Sprintf("scopes.%v", name))
+
+		for _, kind := range FlakeConfigurationKinds {
+			for _, configName := range configurations[kind.Attr] {
+				scopeName := fmt.Sprintf("%v/%v", name, configName)
+
+				if _, exists := c.Scopes[scopeName]; exists {
+					return ValidationError{
+						Msg:    fmt.Sprintf("scope '%v' generated from flake %v conflicts with another scope of the same name", scopeName, s.Flake),
+						Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.flake", name)),
+					}
+				}
+
+				generated := NewFlakeScope(s.Flake, kind, configName)
+				generated.Name = scopeName
+				generated.EvaluatorOutput = s.EvaluatorOutput
+				generated.DefinitionsCmd = s.DefinitionsCmd
+				generated.Env = s.Env
+				generated.Cwd = s.Cwd
+				generated.EnvAllowlist = s.EnvAllowlist
+				generated.Order = s.Order
+				generated.Group = cmp.Or(s.Group, name)
+
+				c.Scopes[scopeName] = generated
+				c.fieldOrigins[fmt.Sprintf("scopes.%v", scopeName)] = origin
+			}
+		}
+	}
+
+	return nil
+}
+
+// Settings that scopes generated from a flake define themselves.
+var flakeGeneratedKeys = []string{"options-list-file", "options-list-url", "options-list-cmd", "sources", "evaluator", "batch-evaluator"}
+
+func (c *Config) validateFlakeScope(name string) error {
+	s := c.Scopes[name]
+
+	if s.OptionsListFile != "" || s.OptionsListURL != "" || !s.OptionsListCmd.IsEmpty() || len(s.Sources) > 0 || !s.EvaluatorCmd.IsEmpty() || !s.BatchEvaluatorCmd.IsEmpty() {
+		return ValidationError{
+			Msg:    fmt.Sprintf("scope '%v' sets flake, so it cannot also set %v", name, strings.Join(flakeGeneratedKeys, ", ")),
+			Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.flake", name)),
+		}
+	}
+
+	if _, err := ParseCommandTemplate("flake-show-cmd", s.FlakeShowCmd); err != nil {
+		return ValidationError{
+			Msg:    fmt.Sprintf("invalid flake-show-cmd template for scope '%v': %v", name, err),
+			Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.flake-show-cmd", name)),
+		}
+	}
+
+	return nil
+}
+
+// Create a scope for a configuration in a flake.
+func NewFlakeScope(flake string, kind FlakeConfigurationKind, name string) Scope {
+	configRef := FlakeRef(flake, kind.Attr, name)
+
+	return Scope{
+		Description: fmt.Sprintf("%v configuration %v", kind.Name, configRef),
+		OptionsListCmd: Command{
+			Argv: []string{"nix", "eval", "--json", configRef, "--apply", optionsListApplyExpr},
+		},
+		EvaluatorCmd: Command{
+			Argv: []string{"nix", "eval", configRef + ".config.{{ nixAttrPath .Location }}"},
+		},
+		BatchEvaluatorCmd: Command{
+			Argv: []string{"nix", "eval", "--json", configRef + ".config", "--apply", batchEvaluatorApplyExpr},
+		},
+	}
+}
+
+// Create a scope for the NixOS system configuration found through
+// `<nixpkgs/nixos>` in `NIX_PATH`, for systems without flakes.
+func NewLegacyNixOSScope() Scope {
+	return Scope{
+		Description: "NixOS configuration from <nixpkgs/nixos>",
+		OptionsListCmd: Command{
+			Argv: []string{
+				"nix-instantiate", "--eval", "--strict", "--json", "--expr",
+				"let system = import <nixpkgs/nixos> {}; in " +
+					"builtins.filter (v: v.visible && !v.internal) (system.pkgs.lib.optionAttrSetToDocList system.options)",
+			},
+		},
+		EvaluatorCmd: Command{
+			Argv: []string{"nix-instantiate", "--eval", "<nixpkgs/nixos>", "-A", "config.{{ nixAttrPath .Location }}"},
+		},
+		BatchEvaluatorCmd: Command{
+			Argv: []string{
+				"nix-instantiate", "--eval", "--strict", "--json", "--expr",
+				"(" + batchEvaluatorApplyExpr + ") (import <nixpkgs/nixos> {}).config",
+			},
+		},
+	}
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bytes"
+	encjson "encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+)
+
+// Environment variable that replaces the default configuration
+// locations with a list of files, separated like `PATH`.
+const ConfigLocationsEnvVar = "OPTNIX_CONFIG"
+
+// Configuration files to load before project configuration files.
+// These are DefaultConfigLocations, unless `$OPTNIX_CONFIG` is set;
+// if it is set but empty, no default files are loaded at all.
+func DefaultLocations() []string {
+	value, ok := os.LookupEnv(ConfigLocationsEnvVar)
+	if !ok {
+		return DefaultConfigLocations
+	}
+
+	var locations []string
+	for _, loc := range filepath.SplitList(value) {
+		if loc != "" {
+			locations = append(locations, loc)
+		}
+	}
+
+	return locations
+}
+
+// Find the parser for a configuration file based on its extension.
+// Files are TOML unless they end in `.json`, `.yaml`, or `.yml`.
+func ParserFor(path string) koanf.Parser {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return indentedJSONParser{json.Parser()}
+	case ".yaml", ".yml":
+		return yaml.Parser()
+	default:
+		return toml.Parser()
+	}
+}
+
+// Writes JSON indented, since configuration files are meant to be
+// edited by hand.
+type indentedJSONParser struct {
+	*json.JSON
+}
+
+func (p indentedJSONParser) Marshal(m map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := encjson.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type configLoader struct {
+	k *koanf.Koanf
+
+	fieldOrigins map[string]string
+	// Files that were loaded, in order
+	loaded []string
+	// Files that were included, keyed by the file that included them
+	includes map[string][]string
+	// Keys with values that refer to environment variables, keyed
+	// by the file that set them
+	envKeys map[string][]string
+}
+
+func newConfigLoader() *configLoader {
+	return &configLoader{
+		k:            koanf.New("."),
+		fieldOrigins: make(map[string]string),
+		includes:     make(map[string][]string),
+		envKeys:      make(map[string][]string),
+	}
+}
+
+// Load a configuration file and merge it on top of the files that
+// were loaded before it, followed by any files that it includes.
+//
+// Files that do not exist are skipped, and each file is only loaded
+// once, even if it is included more than once.
+func (l *configLoader) load(loc string) error {
+	if _, err := os.Stat(loc); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	// Record the real location of each file, so that origins stay
+	// meaningful regardless of where optnix is run from.
+	if absLoc, err := filepath.Abs(loc); err == nil {
+		loc = absLoc
+	}
+
+	if slices.Contains(l.loaded, loc) {
+		return nil
+	}
+
+	fileK := koanf.New(".")
+
+	if err := fileK.Load(file.Provider(loc), ParserFor(loc)); err != nil {
+		return fmt.Errorf("%v: %w", loc, err)
+	}
+
+	envKeys, err := expandEnvValues(fileK, loc)
+	if err != nil {
+		return err
+	}
+	l.envKeys[loc] = envKeys
+
+	includes, err := includePatterns(fileK, loc)
+	if err != nil {
+		return err
+	}
+	fileK.Delete("include")
+
+	if err := resolveRelativePaths(fileK, filepath.Dir(loc)); err != nil {
+		return err
+	}
+
+	for _, key := range fileK.Keys() {
+		l.fieldOrigins[key] = loc
+	}
+
+	// Also load incomplete scope keys into the field origins, since
+	// scopes without proper definitions can technically exist.
+	if scopesMap, ok := fileK.Get("scopes").(map[string]interface{}); ok {
+		for scopeName := range scopesMap {
+			scopeKey := fmt.Sprintf("scopes.%s", scopeName)
+			l.fieldOrigins[scopeKey] = loc
+		}
+	}
+
+	if err := l.k.Merge(fileK); err != nil {
+		return err
+	}
+
+	l.loaded = append(l.loaded, loc)
+
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(loc), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%v: invalid include pattern '%v': %w", loc, pattern, err)
+		}
+
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				continue
+			}
+
+			loaded := len(l.loaded)
+			if err := l.load(match); err != nil {
+				return err
+			}
+
+			// Only record files that were loaded by this include,
+			// and not ones that were already loaded before it.
+			if len(l.loaded) > loaded {
+				l.includes[loc] = append(l.includes[loc], l.loaded[loaded])
+			}
+		}
+	}
+
+	return nil
+}
+
+func includePatterns(k *koanf.Koanf, loc string) ([]string, error) {
+	switch v := k.Get("include").(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		patterns := make([]string, 0, len(v))
+		for _, p := range v {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v: include must be a list of paths, got %v", loc, FormatValue(v))
+			}
+			patterns = append(patterns, s)
+		}
+		return patterns, nil
+	default:
+		return nil, fmt.Errorf("%v: include must be a list of paths, got %v", loc, FormatValue(v))
+	}
+}
+
+// Matches `${NAME}` and `${NAME:-default}`, as well as `$${...}`
+// for writing them literally.
+var envReferenceRegex = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Replace references to environment variables in all string values
+// of a configuration file, and return the keys that had any.
+//
+// Commands are left alone, since shells and Nix expressions use the
+// same syntax; they can read the environment through `.Env` instead.
+func expandEnvValues(k *koanf.Koanf, loc string) ([]string, error) {
+	var envKeys []string
+
+	for key, value := range k.All() {
+		if isCommandTemplateKey(key) {
+			continue
+		}
+
+		expanded, referenced, err := expandEnv(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: failed to expand %v: %w", loc, key, err)
+		}
+
+		if referenced {
+			envKeys = append(envKeys, key)
+		}
+
+		if err := k.Set(key, expanded); err != nil {
+			return nil, err
+		}
+	}
+
+	return envKeys, nil
+}
+
+func expandEnv(value any) (any, bool, error) {
+	switch v := value.(type) {
+	case string:
+		return expandEnvString(v)
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		referenced := false
+		for i, item := range v {
+			e, r, err := expandEnv(item)
+			if err != nil {
+				return nil, false, err
+			}
+			expanded[i] = e
+			referenced = referenced || r
+		}
+		return expanded, referenced, nil
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		referenced := false
+		for key, item := range v {
+			// The commands of options sources are not expanded,
+			// like other commands.
+			if key == "cmd" {
+				expanded[key] = item
+				continue
+			}
+
+			e, r, err := expandEnv(item)
+			if err != nil {
+				return nil, false, err
+			}
+			expanded[key] = e
+			referenced = referenced || r
+		}
+		return expanded, referenced, nil
+	default:
+		return value, false, nil
+	}
+}
+
+// Expand references to environment variables in a string, also
+// returning whether or not it had any (other than escaped ones).
+func expandEnvString(s string) (string, bool, error) {
+	var err error
+	referenced := false
+
+	expanded := envReferenceRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		match := envReferenceRegex.FindStringSubmatch(ref)
+		escaped, name, hasDefault, def := match[1] != "", match[2], match[3] != "", match[4]
+
+		if escaped {
+			return ref[1:]
+		}
+		referenced = true
+
+		if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+			return value
+		}
+
+		if hasDefault {
+			return def
+		}
+
+		if err == nil {
+			err = fmt.Errorf("environment variable '%v' is not set", name)
+		}
+		return ref
+	})
+
+	return expanded, referenced, err
+}
+
+// Files that were included by the given configuration files, either
+// directly or through other included files.
+func (c *Config) IncludedFiles(locations ...string) []string {
+	var included []string
+
+	var visit func(loc string)
+	visit = func(loc string) {
+		for _, inc := range c.includes[loc] {
+			if slices.Contains(included, inc) {
+				continue
+			}
+			included = append(included, inc)
+			visit(inc)
+		}
+	}
+
+	for _, loc := range locations {
+		if abs, err := filepath.Abs(loc); err == nil {
+			loc = abs
+		}
+		visit(loc)
+	}
+
+	return included
+}
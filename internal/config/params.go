@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Separates the name of a scope from the values of its parameters,
+// such as in `nixos@web01`.
+const ScopeParamSeparator = "@"
+
+// Parameter names must be usable in templates as `.Params.<name>`.
+var paramNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Whether or not this scope has parameters that do not have values
+// yet, and must be bound before it can be used.
+func (s Scope) NeedsParams() bool {
+	return len(s.Params) > 0 && s.BoundParams == nil
+}
+
+// Describe how to select this scope, i.e. `nixos@<host>`.
+func (s Scope) Usage() string {
+	if !s.NeedsParams() {
+		return s.Name
+	}
+
+	placeholders := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		placeholders[i] = fmt.Sprintf("<%v>", p)
+	}
+
+	return s.Name + ScopeParamSeparator + strings.Join(placeholders, ",")
+}
+
+// Bind values to the parameters of this scope. Values are separated
+// by commas, and are either given in the order the parameters are
+// declared in, or by name (i.e. `host=web01`).
+func (s Scope) Bind(args string) (Scope, error) {
+	if len(s.Params) == 0 {
+		return s, fmt.Errorf("scope '%v' does not have any parameters", s.Name)
+	}
+
+	values := make(map[string]string, len(s.Params))
+	var positional []string
+
+	for _, arg := range strings.Split(args, ",") {
+		key, value, named := strings.Cut(arg, "=")
+		if !named {
+			positional = append(positional, arg)
+			continue
+		}
+
+		if !slices.Contains(s.Params, key) {
+			return s, fmt.Errorf("scope '%v' does not have a parameter named '%v'", s.Name, key)
+		}
+		if _, ok := values[key]; ok {
+			return s, fmt.Errorf("parameter '%v' was given more than once", key)
+		}
+
+		values[key] = value
+	}
+
+	for _, p := range s.Params {
+		if _, ok := values[p]; ok {
+			continue
+		}
+
+		if len(positional) == 0 {
+			return s, fmt.Errorf("missing value for parameter '%v' of scope '%v'", p, s.Name)
+		}
+
+		values[p] = positional[0]
+		positional = positional[1:]
+	}
+
+	if len(positional) > 0 {
+		return s, fmt.Errorf("too many values for scope '%v', expected %v", s.Name, s.Usage())
+	}
+
+	ordered := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		if values[p] == "" {
+			return s, fmt.Errorf("value for parameter '%v' of scope '%v' must not be empty", p, s.Name)
+		}
+		ordered[i] = values[p]
+	}
+
+	bound := s
+	bound.Name = s.Name + ScopeParamSeparator + strings.Join(ordered, ",")
+	bound.BoundParams = values
+
+	return bound, nil
+}
+
+// Find a scope by name or alias, binding its parameters if the name includes
+// values for them (i.e. `nixos@web01`).
+func (c *Config) ResolveScope(name string) (Scope, error) {
+	if s, ok := c.lookupScope(name); ok {
+		if s.NeedsParams() {
+			return s, fmt.Errorf("scope '%v' requires values for its parameters, i.e. %v", name, s.Usage())
+		}
+		return s, nil
+	}
+
+	if s, values, ok := c.SplitScopeParams(name); ok {
+		return s.Bind(values)
+	}
+
+	if name == StdinScopeName {
+		return NewStdinScope(), nil
+	}
+
+	return Scope{}, fmt.Errorf("scope '%v' not found in configuration", name)
+}
+
+// Split a scope name with parameter values (i.e. `nixos@web01`) into
+// the scope with parameters, and the unparsed values.
+func (c *Config) SplitScopeParams(name string) (Scope, string, bool) {
+	// Scope names can contain the separator themselves, so try
+	// every position it appears at.
+	for i := range len(name) {
+		if !strings.HasPrefix(name[i:], ScopeParamSeparator) {
+			continue
+		}
+
+		s, ok := c.lookupScope(name[:i])
+		if !ok || len(s.Params) == 0 {
+			continue
+		}
+
+		return s, name[i+len(ScopeParamSeparator):], true
+	}
+
+	return Scope{}, "", false
+}
+
+// Retrieve possible values for a parameter of this scope, using its
+// `param-values-cmd`. There are no suggestions if it is not set.
+func (s Scope) ParamChoices(param string) ([]string, error) {
+	cmd, ok := s.ParamValuesCmd[param]
+	if !ok || cmd.IsEmpty() {
+		return nil, nil
+	}
+
+	tmpl, err := ParseCommandTemplate("param-values-cmd", cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := tmpl.Execute(NewCommandTemplateData(s, "", nil))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.Exec(command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list values for parameter '%v': %v", param, strings.TrimSpace(output.Stderr))
+	}
+
+	var choices []string
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			choices = append(choices, line)
+		}
+	}
+
+	return choices, nil
+}
+
+func (c *Config) validateScopeParams(name string, s Scope) error {
+	seen := make(map[string]bool, len(s.Params))
+
+	for _, p := range s.Params {
+		if !paramNameRegex.MatchString(p) {
+			return ValidationError{
+				Msg:    fmt.Sprintf("invalid parameter name '%v' for scope '%v', names may only contain letters, digits, and underscores", p, name),
+				Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.params", name)),
+			}
+		}
+
+		if seen[p] {
+			return ValidationError{
+				Msg:    fmt.Sprintf("parameter '%v' is declared more than once for scope '%v'", p, name),
+				Origin: c.FieldOrigin(fmt.Sprintf("scopes.%v.params", name)),
+			}
+		}
+		seen[p] = true
+	}
+
+	for p, cmd := range s.ParamValuesCmd {
+		key := fmt.Sprintf("scopes.%v.param-values-cmd.%v", name, p)
+
+		if !seen[p] {
+			return ValidationError{
+				Msg:    fmt.Sprintf("param-values-cmd is set for '%v', but scope '%v' has no such parameter", p, name),
+				Origin: c.FieldOrigin(key),
+			}
+		}
+
+		if _, err := ParseCommandTemplate("param-values-cmd", cmd); err != nil {
+			return ValidationError{
+				Msg:    fmt.Sprintf("invalid param-values-cmd template for parameter '%v' of scope '%v': %v", p, name, err),
+				Origin: c.FieldOrigin(key),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"snare.dev/optnix/internal/clipboard"
+	"snare.dev/optnix/option"
+)
+
+// Descriptions of configuration settings, used for the JSON Schema.
+var settingDescriptions = map[string]string{
+	"min_score":          "Minimum score required for search matches",
+	"debounce_time":      "Debounce time for search, in milliseconds",
+	"default_scope":      "Default scope to use if not specified on the command line",
+	"formatter_cmd":      "External formatter command for evaluated values, such as nixfmt",
+	"formatter_indent":   "Number of spaces to indent nested values with in the built-in formatter",
+	"formatter_width":    "Maximum line width for the built-in formatter",
+	"clipboard_backends": "Clipboard backends to try when copying, in order",
+	"scopes":             "Scopes that options can be searched in, keyed by name",
+	"include":            "Other configuration files to load after this one; glob patterns are allowed",
+
+	"description":       "Description of the scope",
+	"order":             "Position of the scope when listed; lower values come first",
+	"aliases":           "Other names the scope can be selected with",
+	"group":             "Heading to list the scope under",
+	"extends":           "Scope to inherit settings from",
+	"options-list-file": "Path to a JSON file containing an options list",
+	"options-list-cmd":  "Command that prints a JSON options list",
+	"options-list-url":  "URL to fetch a JSON options list from, which is cached locally",
+	"evaluator":         "Command template that evaluates an option's value",
+	"evaluator-output":  "Format of values printed by the evaluator",
+	"definitions":       "Command template that prints where an option is defined",
+	"batch-evaluator":   "Command template that evaluates several options at once, printing a JSON list",
+	"sources":           "More places to load options from, which are merged together",
+	"env":               "Extra environment variables for commands",
+	"cwd":               "Working directory to run commands in",
+	"env-allowlist":     "Environment variables to pass through to commands; glob patterns are allowed",
+	"flake":             "Flake to generate a scope from for each of its configurations",
+	"flake-show-cmd":    "Command template that prints the configurations in a flake as JSON",
+	"params":            "Names of parameters that must be given values when selecting the scope",
+	"param-values-cmd":  "Commands that print possible values of each parameter, one per line",
+
+	"options-list-url-auth-env": "Environment variable with the Authorization header to send to options-list-url",
+
+	"name":     "Name recorded as the source of each option",
+	"file":     "Path to a JSON file containing an options list",
+	"glob":     "Pattern of JSON files containing options lists",
+	"url":      "URL to fetch a JSON options list from, which is cached locally",
+	"auth-env": "Environment variable with the Authorization header to send to url",
+	"cmd":      "Command that prints a JSON options list",
+	"priority": "Options from sources with a higher priority replace options with the same name",
+}
+
+// Allowed values for settings that only accept specific strings.
+var settingEnums = map[string][]string{
+	"evaluator-output": {string(option.EvaluatorOutputNix), string(option.EvaluatorOutputJSON)},
+}
+
+type settingInfo struct {
+	Key  string
+	Type reflect.Type
+}
+
+// Find all settings in a configuration struct, through their
+// koanf tags.
+func structSettings(t reflect.Type) []settingInfo {
+	var settings []settingInfo
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		key := field.Tag.Get("koanf")
+		if key == "" || key == "-" || !field.IsExported() {
+			continue
+		}
+
+		settings = append(settings, settingInfo{Key: key, Type: field.Type})
+	}
+
+	return settings
+}
+
+var (
+	configSettings = structSettings(reflect.TypeOf(Config{}))
+	scopeSettings  = structSettings(reflect.TypeOf(Scope{}))
+)
+
+func settingKeys(settings []settingInfo) []string {
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+func findSetting(settings []settingInfo, key string) (settingInfo, bool) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return settingInfo{}, false
+}
+
+// Ensure that all keys set in configuration files are known, so
+// that typos do not silently get ignored.
+func (c *Config) validateKeys() error {
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		segments := strings.Split(key, ".")
+
+		setting, ok := findSetting(configSettings, segments[0])
+		if !ok {
+			return c.unknownKeyError(key, segments[0], settingKeys(configSettings), "")
+		}
+
+		if setting.Type.Kind() != reflect.Map || setting.Key != "scopes" || len(segments) < 3 {
+			continue
+		}
+
+		scopeName := segments[1]
+		scopeSetting, ok := findSetting(scopeSettings, segments[2])
+		if !ok {
+			return c.unknownKeyError(key, segments[2], settingKeys(scopeSettings), scopeName)
+		}
+
+		// Sources must be a list of tables; a single table is
+		// flattened into separate keys instead.
+		if scopeSetting.Key == "sources" {
+			if _, ok := c.values[key].([]interface{}); !ok {
+				return ValidationError{
+					Msg:    fmt.Sprintf("sources of scope '%v' must be a list of tables, such as [[scopes.%v.sources]]", scopeName, scopeName),
+					Origin: c.FieldOrigin(key),
+				}
+			}
+		}
+
+		// Only maps (such as `env`) can have arbitrary keys under them.
+		if len(segments) > 3 && scopeSetting.Type.Kind() != reflect.Map {
+			return c.unknownKeyError(key, strings.Join(segments[2:], "."), nil, scopeName)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) unknownKeyError(key string, name string, candidates []string, scope string) error {
+	msg := fmt.Sprintf("unknown setting '%v'", name)
+	if scope != "" {
+		msg = fmt.Sprintf("unknown setting '%v' for scope '%v'", name, scope)
+	}
+
+	if suggestion := closestMatch(name, candidates); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean '%v'?", suggestion)
+	}
+
+	return ValidationError{
+		Msg:    msg,
+		Origin: c.FieldOrigin(key),
+	}
+}
+
+// Find the candidate closest to a misspelled name, if any of them
+// are close enough to be a likely typo.
+func closestMatch(name string, candidates []string) string {
+	best := ""
+	bestDistance := max(2, len(name)/3) + 1
+
+	for _, c := range candidates {
+		// Mixing up dashes and underscores is a common mistake, since
+		// top-level settings and scope settings use different ones.
+		normalized := strings.ReplaceAll(name, "_", "-")
+		if normalized == strings.ReplaceAll(c, "_", "-") {
+			return c
+		}
+
+		if d := levenshtein(name, c); d < bestDistance {
+			best = c
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Generate a JSON Schema of the configuration file format, for
+// validating configuration files in editors.
+func Schema() map[string]any {
+	scopeSchema := settingsSchema(scopeSettings)
+	scopeSchema["description"] = "A scope to search options in"
+
+	schema := settingsSchema(configSettings)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "optnix configuration"
+	schema["$defs"] = map[string]any{
+		"scope": scopeSchema,
+	}
+
+	return schema
+}
+
+func settingsSchema(settings []settingInfo) map[string]any {
+	properties := make(map[string]any, len(settings))
+
+	for _, s := range settings {
+		var prop map[string]any
+		if s.Key == "scopes" {
+			prop = map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"$ref": "#/$defs/scope"},
+			}
+		} else {
+			prop = typeSchema(s.Type)
+		}
+
+		if desc, ok := settingDescriptions[s.Key]; ok {
+			prop["description"] = desc
+		}
+
+		if values, ok := settingEnums[s.Key]; ok {
+			prop["enum"] = values
+		}
+
+		if s.Key == "clipboard_backends" {
+			backends := make([]string, len(clipboard.AvailableBackends))
+			for i, b := range clipboard.AvailableBackends {
+				backends[i] = string(b)
+			}
+			prop["items"] = map[string]any{"type": "string", "enum": backends}
+		}
+
+		properties[s.Key] = prop
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if t == commandType {
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string"},
+				map[string]any{
+					"type":     "array",
+					"items":    map[string]any{"type": "string"},
+					"minItems": 1,
+				},
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return settingsSchema(structSettings(t))
+	}
+
+	return map[string]any{}
+}
@@ -4,22 +4,88 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
+	"slices"
+	"strings"
 
 	"snare.dev/optnix/internal/utils"
 	"snare.dev/optnix/option"
 )
 
 type Scope struct {
-	Name            string `koanf:"-"`
-	Description     string `koanf:"description"`
-	OptionsListFile string `koanf:"options-list-file"`
-	OptionsListCmd  string `koanf:"options-list-cmd"`
-	EvaluatorCmd    string `koanf:"evaluator"`
+	Name            string  `koanf:"-"`
+	Description     string  `koanf:"description"`
+	OptionsListFile string  `koanf:"options-list-file"`
+	OptionsListCmd  Command `koanf:"options-list-cmd"`
+	EvaluatorCmd    Command `koanf:"evaluator"`
+	EvaluatorOutput string  `koanf:"evaluator-output"`
+	DefinitionsCmd  Command `koanf:"definitions"`
+	// Command template that evaluates several options at once, and
+	// prints a JSON list of their values
+	BatchEvaluatorCmd Command `koanf:"batch-evaluator"`
+	// URL to fetch an options list from over HTTP
+	OptionsListURL string `koanf:"options-list-url"`
+	// Environment variable with the value of the Authorization
+	// header to send when fetching the options list URL
+	OptionsListURLAuthEnv string `koanf:"options-list-url-auth-env"`
+	// More places to load options from, which are merged with the
+	// options list file or command
+	Sources []OptionsSource `koanf:"sources"`
+
+	// Position of this scope when listed; lower values come first,
+	// and scopes with the same order are sorted by name
+	Order int `koanf:"order"`
+	// Other names this scope can be selected with
+	Aliases []string `koanf:"aliases"`
+	// Heading to list this scope under
+	Group string `koanf:"group"`
+	// Scope to inherit settings from
+	Extends string `koanf:"extends"`
+
+	// Extra environment variables to set for commands
+	Env map[string]string `koanf:"env"`
+	// Working directory to run commands in
+	Cwd string `koanf:"cwd"`
+	// Names (or glob patterns) of environment variables to pass
+	// through to commands; if unset, all variables are passed.
+	EnvAllowlist []string `koanf:"env-allowlist"`
+
+	// Flake to generate a scope from for each of its configurations,
+	// instead of defining this scope's options directly
+	Flake string `koanf:"flake"`
+	// Command that prints the configurations in the flake as JSON
+	FlakeShowCmd Command `koanf:"flake-show-cmd"`
+
+	// Names of parameters that must be given values when selecting
+	// this scope, which are available to templates as `.Params`
+	Params []string `koanf:"params"`
+	// Commands that print possible values of each parameter, one
+	// per line, for completion
+	ParamValuesCmd map[string]Command `koanf:"param-values-cmd"`
+	// Values of parameters, once they have been bound
+	BoundParams map[string]string `koanf:"-"`
+}
+
+// Name of the scope that reads options from standard input, which
+// is available without being configured.
+const StdinScopeName = "-"
+
+// Create a scope that reads an options list from standard input.
+func NewStdinScope() Scope {
+	return Scope{
+		Name:            StdinScopeName,
+		Description:     "Options read from standard input",
+		OptionsListFile: StdinPath,
+	}
 }
 
 func (s Scope) Load() (option.NixosOptionSource, error) {
+	if len(s.Sources) > 0 {
+		return s.loadOptionsSources()
+	}
+
 	if s.OptionsListFile != "" {
-		optionsFile, err := os.Open(s.OptionsListFile)
+		optionsFile, err := openOptionsFile(s.OptionsListFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open options file: %v", err)
 		} else {
@@ -34,8 +100,17 @@ func (s Scope) Load() (option.NixosOptionSource, error) {
 		}
 	}
 
-	if s.OptionsListCmd != "" {
-		l, err := runGenerateOptionListCmd(s.OptionsListCmd)
+	if s.OptionsListURL != "" {
+		l, err := fetchOptionsList(s.OptionsListURL, s.OptionsListURLAuthEnv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch options from %v: %v", s.OptionsListURL, err)
+		}
+
+		return l, nil
+	}
+
+	if !s.OptionsListCmd.IsEmpty() {
+		l, err := s.runGenerateOptionListCmd("options-list-cmd", s.OptionsListCmd)
 		if err != nil {
 			return nil, fmt.Errorf("failed to run options cmd: %v", err)
 		}
@@ -46,8 +121,26 @@ func (s Scope) Load() (option.NixosOptionSource, error) {
 	return nil, fmt.Errorf("no options found through all strategies for scope '%v'", s.Name)
 }
 
-func runGenerateOptionListCmd(commandStr string) (option.NixosOptionSource, error) {
-	cmdOutput, err := utils.ExecShellAndCaptureOutput(commandStr)
+func (s Scope) runGenerateOptionListCmd(name string, cmd Command) (option.NixosOptionSource, error) {
+	if s.NeedsParams() {
+		return nil, fmt.Errorf("scope '%v' requires values for its parameters, i.e. %v", s.Name, s.Usage())
+	}
+
+	// Options list commands are only templates for scopes with
+	// parameters, since they do not depend on anything else.
+	if len(s.Params) > 0 {
+		tmpl, err := ParseCommandTemplate(name, cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd, err = tmpl.Execute(NewCommandTemplateData(s, "", nil))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmdOutput, err := s.Exec(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -60,3 +153,50 @@ func runGenerateOptionListCmd(commandStr string) (option.NixosOptionSource, erro
 
 	return l, nil
 }
+
+// The environment that commands for this scope are run with.
+func (s Scope) Environ() []string {
+	env := []string{}
+
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if s.EnvAllowlist == nil || envAllowed(s.EnvAllowlist, name) {
+			env = append(env, kv)
+		}
+	}
+
+	names := make([]string, 0, len(s.Env))
+	for name := range s.Env {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		env = append(env, name+"="+s.Env[name])
+	}
+
+	return env
+}
+
+func envAllowed(allowlist []string, name string) bool {
+	for _, pattern := range allowlist {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Run a command with this scope's environment and working directory,
+// and capture its output.
+func (s Scope) Exec(cmd Command) (utils.ShellExecOutput, error) {
+	argv := cmd.Argv
+	if !cmd.IsArgv() {
+		argv = []string{"/bin/sh", "-c", cmd.Shell}
+	}
+
+	return utils.ExecAndCaptureOutput(argv, utils.ExecOptions{
+		Dir: s.Cwd,
+		Env: s.Environ(),
+	})
+}
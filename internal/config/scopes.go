@@ -0,0 +1,245 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Scopes in the order they should be listed in: by their `order`,
+// then by name. Scopes in the same group are kept together, and
+// each group is placed where its first scope would be.
+func (c *Config) OrderedScopes() []Scope {
+	scopes := slices.Collect(maps.Values(c.Scopes))
+
+	slices.SortFunc(scopes, func(a, b Scope) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), strings.Compare(a.Name, b.Name))
+	})
+
+	groupRank := make(map[string]int)
+	for _, s := range scopes {
+		if _, ok := groupRank[s.Group]; !ok {
+			groupRank[s.Group] = len(groupRank)
+		}
+	}
+
+	slices.SortStableFunc(scopes, func(a, b Scope) int {
+		return cmp.Compare(groupRank[a.Group], groupRank[b.Group])
+	})
+
+	return scopes
+}
+
+// Find a scope by its name or one of its aliases.
+func (c *Config) lookupScope(name string) (Scope, bool) {
+	if s, ok := c.Scopes[name]; ok {
+		return s, true
+	}
+
+	for _, s := range c.Scopes {
+		if slices.Contains(s.Aliases, name) {
+			return s, true
+		}
+	}
+
+	return Scope{}, false
+}
+
+// Settings that are never inherited through `extends`.
+var nonInheritedScopeKeys = []string{"extends", "aliases"}
+
+// Apply `extends` to all scopes, so that each scope has the settings
+// of the scope it extends, unless it sets them itself. Tables such as
+// `env` are merged instead.
+//
+// Scopes that are only used as a base for others (i.e. they have no
+// options list of their own) are removed afterwards, since they
+// cannot be used by themselves.
+//
+// Unknown or circular bases are left for Validate to report.
+func (c *Config) resolveScopeInheritance() {
+	resolved := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var resolve func(name string)
+	resolve = func(name string) {
+		if resolved[name] || visiting[name] {
+			return
+		}
+
+		s := c.Scopes[name]
+		if _, ok := c.Scopes[s.Extends]; s.Extends == "" || !ok {
+			resolved[name] = true
+			return
+		}
+
+		visiting[name] = true
+		resolve(s.Extends)
+		visiting[name] = false
+
+		c.Scopes[name] = c.inheritScope(s, c.Scopes[s.Extends])
+		resolved[name] = true
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Scopes)) {
+		resolve(name)
+	}
+
+	c.baseScopes = make(map[string]Scope)
+	for _, s := range c.Scopes {
+		base, ok := c.Scopes[s.Extends]
+		if !ok || base.Name == s.Name || base.hasOptionsSource() {
+			continue
+		}
+		c.baseScopes[base.Name] = base
+	}
+	for name := range c.baseScopes {
+		delete(c.Scopes, name)
+	}
+}
+
+func (s Scope) hasOptionsSource() bool {
+	return s.OptionsListFile != "" || s.OptionsListURL != "" || !s.OptionsListCmd.IsEmpty() || len(s.Sources) > 0 || s.Flake != ""
+}
+
+func (c *Config) inheritScope(child Scope, base Scope) Scope {
+	childValue := reflect.ValueOf(&child).Elem()
+	baseValue := reflect.ValueOf(base)
+	t := childValue.Type()
+
+	for i := range t.NumField() {
+		key := t.Field(i).Tag.Get("koanf")
+		if key == "" || key == "-" || slices.Contains(nonInheritedScopeKeys, key) {
+			continue
+		}
+
+		field := childValue.Field(i)
+		baseField := baseValue.Field(i)
+
+		if !c.scopeKeyIsSet(child.Name, key) {
+			field.Set(baseField)
+			c.inheritFieldOrigins(child.Name, base.Name, key)
+			continue
+		}
+
+		if field.Kind() == reflect.Map && !baseField.IsNil() {
+			merged := reflect.MakeMap(field.Type())
+			for _, m := range []reflect.Value{baseField, field} {
+				iter := m.MapRange()
+				for iter.Next() {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			field.Set(merged)
+			c.inheritFieldOrigins(child.Name, base.Name, key)
+		}
+	}
+
+	return child
+}
+
+// Whether or not a setting of a scope was set in any configuration
+// file, including as a table.
+func (c *Config) scopeKeyIsSet(scope string, key string) bool {
+	prefix := fmt.Sprintf("scopes.%v.%v", scope, key)
+
+	for k := range c.values {
+		if k == prefix || strings.HasPrefix(k, prefix+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Copy the origins of a base scope's settings to a scope that
+// inherits them, unless they were set by the scope itself.
+func (c *Config) inheritFieldOrigins(scope string, base string, key string) {
+	basePrefix := fmt.Sprintf("scopes.%v.%v", base, key)
+
+	for k, origin := range c.fieldOrigins {
+		if k != basePrefix && !strings.HasPrefix(k, basePrefix+".") {
+			continue
+		}
+
+		inherited := fmt.Sprintf("scopes.%v.%v", scope, strings.TrimPrefix(k, fmt.Sprintf("scopes.%v.", base)))
+		if _, ok := c.fieldOrigins[inherited]; !ok {
+			c.fieldOrigins[inherited] = origin
+		}
+	}
+}
+
+func (c *Config) validateScopeExtends(name string, s Scope) error {
+	if s.Extends == "" {
+		return nil
+	}
+
+	origin := c.FieldOrigin(fmt.Sprintf("scopes.%v.extends", name))
+
+	// Follow the chain of bases, which must end without coming back
+	// to the same scope.
+	seen := map[string]bool{name: true}
+	current := s
+
+	for current.Extends != "" {
+		base, ok := c.Scopes[current.Extends]
+		if !ok {
+			base, ok = c.baseScopes[current.Extends]
+		}
+		if !ok {
+			return ValidationError{
+				Msg:    fmt.Sprintf("scope '%v' extends unknown scope '%v'", current.Name, current.Extends),
+				Origin: origin,
+			}
+		}
+
+		if seen[base.Name] {
+			return ValidationError{
+				Msg:    fmt.Sprintf("scope '%v' extends itself through '%v'", name, current.Name),
+				Origin: origin,
+			}
+		}
+
+		seen[base.Name] = true
+		current = base
+	}
+
+	return nil
+}
+
+func (c *Config) validateScopeAliases() error {
+	owners := make(map[string]string)
+
+	for _, s := range c.OrderedScopes() {
+		for _, alias := range s.Aliases {
+			origin := c.FieldOrigin(fmt.Sprintf("scopes.%v.aliases", s.Name))
+
+			if alias == "" || strings.Contains(alias, ScopeParamSeparator) {
+				return ValidationError{
+					Msg:    fmt.Sprintf("invalid alias '%v' for scope '%v'", alias, s.Name),
+					Origin: origin,
+				}
+			}
+
+			if _, ok := c.Scopes[alias]; ok {
+				return ValidationError{
+					Msg:    fmt.Sprintf("alias '%v' for scope '%v' is already the name of a scope", alias, s.Name),
+					Origin: origin,
+				}
+			}
+
+			if owner, ok := owners[alias]; ok {
+				return ValidationError{
+					Msg:    fmt.Sprintf("alias '%v' is used by both scope '%v' and scope '%v'", alias, owner, s.Name),
+					Origin: origin,
+				}
+			}
+			owners[alias] = s.Name
+		}
+	}
+
+	return nil
+}
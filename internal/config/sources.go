@@ -0,0 +1,374 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/mattn/go-isatty"
+	"snare.dev/optnix/option"
+)
+
+// One of several places that a scope loads options from. Exactly one
+// of `file`, `glob`, `url`, or `cmd` must be set.
+type OptionsSource struct {
+	// Name recorded as the source of each option; defaults to the
+	// file or command that the options came from
+	Name string `koanf:"name"`
+	// Path to a JSON file containing an options list
+	File string `koanf:"file"`
+	// Pattern of JSON files containing options lists
+	Glob string `koanf:"glob"`
+	// URL to fetch an options list from over HTTP
+	URL string `koanf:"url"`
+	// Environment variable with the value of the Authorization header
+	// to send when fetching the URL
+	AuthEnv string `koanf:"auth-env"`
+	// Command that prints a JSON options list
+	Cmd Command `koanf:"cmd"`
+	// Options from sources with a higher priority replace options with
+	// the same name from other sources; ties go to the later source
+	Priority int `koanf:"priority"`
+}
+
+// Keys of an options source, in the order they should be listed.
+var optionsSourceKeys = settingKeys(structSettings(reflect.TypeOf(OptionsSource{})))
+
+// A single file or command to load options from, after expanding
+// globs into the files that they match.
+type optionsSourceUnit struct {
+	name     string
+	file     string
+	url      string
+	authEnv  string
+	cmd      Command
+	priority int
+}
+
+// All sources of this scope, with the options list file or command
+// (if any) coming first.
+func (s Scope) optionsSources() []OptionsSource {
+	var sources []OptionsSource
+
+	if s.OptionsListFile != "" {
+		sources = append(sources, OptionsSource{File: s.OptionsListFile})
+	} else if s.OptionsListURL != "" {
+		sources = append(sources, OptionsSource{URL: s.OptionsListURL, AuthEnv: s.OptionsListURLAuthEnv})
+	} else if !s.OptionsListCmd.IsEmpty() {
+		sources = append(sources, OptionsSource{Cmd: s.OptionsListCmd})
+	}
+
+	return append(sources, s.Sources...)
+}
+
+func (s Scope) expandOptionsSources() ([]optionsSourceUnit, error) {
+	var units []optionsSourceUnit
+
+	for _, src := range s.optionsSources() {
+		switch {
+		case src.File != "":
+			name := src.Name
+			if name == "" {
+				name = src.File
+			}
+			units = append(units, optionsSourceUnit{name: name, file: src.File, priority: src.Priority})
+		case src.Glob != "":
+			matches, err := filepath.Glob(src.Glob)
+			if err != nil {
+				return nil, fmt.Errorf("invalid options source pattern '%v': %v", src.Glob, err)
+			}
+
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.IsDir() {
+					continue
+				}
+
+				name := match
+				if src.Name != "" {
+					name = fmt.Sprintf("%v (%v)", src.Name, filepath.Base(match))
+				}
+				units = append(units, optionsSourceUnit{name: name, file: match, priority: src.Priority})
+			}
+		case src.URL != "":
+			name := src.Name
+			if name == "" {
+				name = src.URL
+			}
+			units = append(units, optionsSourceUnit{name: name, url: src.URL, authEnv: src.AuthEnv, priority: src.Priority})
+		case !src.Cmd.IsEmpty():
+			name := src.Name
+			if name == "" {
+				name = src.Cmd.String()
+			}
+			units = append(units, optionsSourceUnit{name: name, cmd: src.Cmd, priority: src.Priority})
+		}
+	}
+
+	return units, nil
+}
+
+// Load every source of this scope concurrently, and merge their
+// options together, recording which source each option came from.
+func (s Scope) loadOptionsSources() (option.NixosOptionSource, error) {
+	units, err := s.expandOptionsSources()
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]option.NixosOptionSource, len(units))
+	errs := make([]error, len(units))
+
+	var wg sync.WaitGroup
+	for i, unit := range units {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var err error
+			switch {
+			case unit.file != "":
+				loaded[i], err = loadOptionsFile(unit.file)
+			case unit.url != "":
+				loaded[i], err = fetchOptionsList(unit.url, unit.authEnv)
+			default:
+				loaded[i], err = s.runGenerateOptionListCmd("sources", unit.cmd)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to load options from %v: %v", unit.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return mergeOptionsSources(units, loaded), nil
+}
+
+// Merge options from several sources, keeping each option at the
+// position it first appeared in. Options with the same name are
+// replaced by ones from sources with a higher or equal priority.
+func mergeOptionsSources(units []optionsSourceUnit, loaded []option.NixosOptionSource) option.NixosOptionSource {
+	var merged option.NixosOptionSource
+	var priorities []int
+	indices := make(map[string]int)
+
+	for i, unit := range units {
+		for _, o := range loaded[i] {
+			o.Source = unit.name
+
+			j, ok := indices[o.Name]
+			if !ok {
+				indices[o.Name] = len(merged)
+				merged = append(merged, o)
+				priorities = append(priorities, unit.priority)
+				continue
+			}
+
+			if unit.priority >= priorities[j] {
+				merged[j] = o
+				priorities[j] = unit.priority
+			}
+		}
+	}
+
+	return merged
+}
+
+// Path that reads an options list from standard input instead of
+// from a file.
+const StdinPath = "-"
+
+// Standard input can only be read once, so keep its contents around
+// for loading the same options again, such as when reloading.
+var readStdin = sync.OnceValues(func() ([]byte, error) {
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		return nil, errors.New("options must be piped in through standard input")
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("no options were given on standard input")
+	}
+
+	return data, nil
+})
+
+func openOptionsFile(path string) (io.ReadCloser, error) {
+	if path == StdinPath {
+		data, err := readStdin()
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	return os.Open(path)
+}
+
+func loadOptionsFile(path string) (option.NixosOptionSource, error) {
+	f, err := openOptionsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return option.LoadOptions(f)
+}
+
+// Files that options are loaded from for this scope, including
+// the current matches of any globs.
+func (s Scope) OptionsFiles() []string {
+	units, err := s.expandOptionsSources()
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, unit := range units {
+		if unit.file != "" && unit.file != StdinPath {
+			files = append(files, unit.file)
+		}
+	}
+
+	return files
+}
+
+// Resolve relative paths in the `file` and `glob` settings of each
+// options source, which are lists that koanf does not flatten.
+func resolveSourcePaths(k *koanf.Koanf, key string, dir string) error {
+	sources, ok := k.Get(key).([]interface{})
+	if !ok {
+		return nil
+	}
+
+	resolved := make([]interface{}, len(sources))
+	for i, src := range sources {
+		srcMap, ok := src.(map[string]interface{})
+		if !ok {
+			resolved[i] = src
+			continue
+		}
+
+		resolvedMap := make(map[string]interface{}, len(srcMap))
+		for name, value := range srcMap {
+			if path, ok := value.(string); ok && (name == "file" || name == "glob") && isRelativePath(path) {
+				value = filepath.Join(dir, path)
+			}
+			resolvedMap[name] = value
+		}
+		resolved[i] = resolvedMap
+	}
+
+	return k.Set(key, resolved)
+}
+
+func (c *Config) validateScopeSources(name string, s Scope) error {
+	key := fmt.Sprintf("scopes.%v.sources", name)
+
+	// Keys of each source are not flattened, so unknown ones need
+	// to be found here instead of along with the other settings.
+	if raw, ok := c.values[key].([]interface{}); ok {
+		for i, src := range raw {
+			srcMap, ok := src.(map[string]interface{})
+			if !ok {
+				return ValidationError{
+					Msg:    fmt.Sprintf("source %d of scope '%v' must be a table, got %v", i+1, name, FormatValue(src)),
+					Origin: c.FieldOrigin(key),
+				}
+			}
+
+			for srcKey := range srcMap {
+				if slices.Contains(optionsSourceKeys, srcKey) {
+					continue
+				}
+
+				msg := fmt.Sprintf("unknown setting '%v' in source %d of scope '%v'", srcKey, i+1, name)
+				if suggestion := closestMatch(srcKey, optionsSourceKeys); suggestion != "" {
+					msg += fmt.Sprintf(", did you mean '%v'?", suggestion)
+				}
+				return ValidationError{Msg: msg, Origin: c.FieldOrigin(key)}
+			}
+		}
+	}
+
+	for i, src := range s.Sources {
+		set := 0
+		for _, isSet := range []bool{src.File != "", src.Glob != "", src.URL != "", !src.Cmd.IsEmpty()} {
+			if isSet {
+				set++
+			}
+		}
+
+		if set != 1 {
+			return ValidationError{
+				Msg:    fmt.Sprintf("source %d of scope '%v' must set exactly one of file, glob, url, or cmd", i+1, name),
+				Origin: c.FieldOrigin(key),
+			}
+		}
+
+		if src.URL != "" {
+			if err := validateOptionsURL(src.URL); err != nil {
+				return ValidationError{
+					Msg:    fmt.Sprintf("invalid url '%v' in source %d of scope '%v': %v", src.URL, i+1, name, err),
+					Origin: c.FieldOrigin(key),
+				}
+			}
+		} else if src.AuthEnv != "" {
+			return ValidationError{
+				Msg:    fmt.Sprintf("source %d of scope '%v' sets auth-env without a url", i+1, name),
+				Origin: c.FieldOrigin(key),
+			}
+		}
+
+		if src.Glob != "" {
+			if _, err := filepath.Match(src.Glob, ""); err != nil {
+				return ValidationError{
+					Msg:    fmt.Sprintf("invalid glob pattern '%v' in source %d of scope '%v': %v", src.Glob, i+1, name, err),
+					Origin: c.FieldOrigin(key),
+				}
+			}
+		}
+
+		if len(s.Params) > 0 && !src.Cmd.IsEmpty() {
+			if _, err := ParseCommandTemplate("sources", src.Cmd); err != nil {
+				return ValidationError{
+					Msg:    fmt.Sprintf("invalid cmd template in source %d of scope '%v': %v", i+1, name, err),
+					Origin: c.FieldOrigin(key),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Whether a list of options sources has any commands in it, or makes
+// requests to any URLs.
+func sourcesNeedTrust(value any) bool {
+	sources, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, src := range sources {
+		if srcMap, ok := src.(map[string]interface{}); ok && (srcMap["cmd"] != nil || srcMap["url"] != nil) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"snare.dev/optnix/option"
+)
+
+// Data available to command templates, such as the evaluator.
+type CommandTemplateData struct {
+	// Option name, as it appears in the options list
+	Option string
+	// Option names, for commands that evaluate several options
+	Options []string
+	// Attribute names of each option in `.Options`
+	Locations [][]string
+	// Name of the scope the command is run for
+	Scope string
+	// Attribute names of the option, i.e. `loc`
+	Location []string
+	// Environment variables the command is run with
+	Env map[string]string
+	// Flake reference of the scope, for discovering configurations
+	Flake string
+	// Values of the scope's parameters
+	Params map[string]string
+}
+
+// Create the data for a command template. The location of the option
+// is taken from the options list; if it is not known, it is found by
+// splitting the option name instead.
+func NewCommandTemplateData(scope Scope, optionName string, location []string) CommandTemplateData {
+	env := make(map[string]string)
+	for _, kv := range scope.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	return CommandTemplateData{
+		Option:   optionName,
+		Scope:    scope.Name,
+		Location: optionLocation(optionName, location),
+		Env:      env,
+		Flake:    scope.Flake,
+		Params:   scope.BoundParams,
+	}
+}
+
+// Data available to the batch evaluator, which receives the names
+// of all options to evaluate instead of a single one.
+func NewBatchCommandTemplateData(scope Scope, optionNames []string, locations [][]string) CommandTemplateData {
+	data := NewCommandTemplateData(scope, "", nil)
+	data.Options = optionNames
+	data.Locations = make([][]string, len(optionNames))
+	for i, name := range optionNames {
+		var location []string
+		if i < len(locations) {
+			location = locations[i]
+		}
+		data.Locations[i] = optionLocation(name, location)
+	}
+	return data
+}
+
+func optionLocation(optionName string, location []string) []string {
+	if len(location) > 0 {
+		return location
+	}
+	if optionName == "" {
+		return nil
+	}
+	return option.SplitOptionName(optionName)
+}
+
+var commandTemplateFuncs = template.FuncMap{
+	"shellQuote":  shellQuote,
+	"nixAttrPath": nixAttrPath,
+	"json":        toJSON,
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(commandTemplateFuncs).Parse(text)
+}
+
+func executeTemplate(tmpl *template.Template, data CommandTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Quote a string for use as a single word in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Format an attribute path for use in a Nix expression, quoting
+// any attribute names that need it. This accepts either an option
+// name or a list of attribute names, such as `.Location`.
+func nixAttrPath(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return option.FormatAttrPath(option.SplitOptionName(v)), nil
+	case []string:
+		return option.FormatAttrPath(v), nil
+	}
+
+	return "", fmt.Errorf("nixAttrPath: expected a string or list of strings, got %T", v)
+}
+
+func toJSON(v any) (string, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
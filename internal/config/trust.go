@@ -0,0 +1,224 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A list of project configuration files that the user has allowed
+// to run commands, along with the hashes of their contents at the
+// time they were trusted.
+type TrustStore struct {
+	// Hashes of trusted files, keyed by absolute path
+	Files map[string]string `json:"files"`
+
+	path string
+}
+
+type TrustStatus int
+
+const (
+	TrustStatusUntrusted TrustStatus = iota
+	TrustStatusTrusted
+	// The file was trusted, but its contents have changed since
+	TrustStatusChanged
+)
+
+// Location of the trust store, depending on `XDG_DATA_HOME` presence.
+func TrustStorePath() (string, error) {
+	if xdgDataHome := os.Getenv("XDG_DATA_HOME"); xdgDataHome != "" {
+		return filepath.Join(xdgDataHome, "optnix", "trusted.json"), nil
+	}
+
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("neither $XDG_DATA_HOME nor $HOME are set")
+	}
+
+	return filepath.Join(home, ".local", "share", "optnix", "trusted.json"), nil
+}
+
+// Load the trust store; if it does not exist yet, an empty one is
+// returned instead.
+func LoadTrustStore() (*TrustStore, error) {
+	path, err := TrustStorePath()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &TrustStore{
+		Files: make(map[string]string),
+		path:  path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse trust store %v: %w", path, err)
+	}
+
+	if store.Files == nil {
+		store.Files = make(map[string]string)
+	}
+
+	return store, nil
+}
+
+func (t *TrustStore) Save() error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(t.path, append(data, '\n'), 0o600)
+}
+
+func (t *TrustStore) Status(path string) (TrustStatus, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return TrustStatusUntrusted, err
+	}
+
+	trustedHash, ok := t.Files[absPath]
+	if !ok {
+		return TrustStatusUntrusted, nil
+	}
+
+	hash, err := hashFile(absPath)
+	if err != nil {
+		return TrustStatusUntrusted, err
+	}
+
+	if hash != trustedHash {
+		return TrustStatusChanged, nil
+	}
+
+	return TrustStatusTrusted, nil
+}
+
+// Trust the current contents of a file. Returns the absolute path
+// of the file that was trusted.
+func (t *TrustStore) Trust(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := hashFile(absPath)
+	if err != nil {
+		return "", err
+	}
+
+	t.Files[absPath] = hash
+
+	return absPath, nil
+}
+
+// Remove a file from the trust store, returning whether or not it
+// was trusted before.
+func (t *TrustStore) Untrust(path string) (bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := t.Files[absPath]
+	delete(t.Files, absPath)
+
+	return ok, nil
+}
+
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Settings of a scope that are run as commands, and are templates
+// rather than plain values.
+var scopeCommandTemplateKeys = []string{"options-list-cmd", "evaluator", "batch-evaluator", "definitions", "flake-show-cmd", "param-values-cmd"}
+
+var scopeCommandKeys = []string{"options-list-cmd", "options-list-url", "options-list-url-auth-env", "extends", "evaluator", "batch-evaluator", "definitions", "env", "env-allowlist", "cwd", "flake", "flake-show-cmd", "param-values-cmd"}
+
+// Find the keys that were set by a configuration file that can
+// cause commands to be run, or that change how they are run.
+//
+// Keys that refer to environment variables are included as well,
+// since their values (such as tokens) could otherwise be sent
+// anywhere, like in the URL of an options list.
+func (c *Config) CommandKeysFrom(file string) []string {
+	var keys []string
+
+	for key, origin := range c.fieldOrigins {
+		if origin != file {
+			continue
+		}
+
+		if slices.Contains(c.envKeys[file], key) {
+			keys = append(keys, key)
+			continue
+		}
+
+		// Sources only need to be trusted if any of them set a
+		// command or fetch from a URL.
+		isSources := strings.HasSuffix(key, ".sources") && strings.HasPrefix(key, "scopes.")
+		if !isCommandKey(key) && !(isSources && sourcesNeedTrust(c.values[key])) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+func isCommandKey(key string) bool {
+	return key == "formatter_cmd" || isScopeKey(key, scopeCommandKeys)
+}
+
+// Whether a key is a command that is run, which is left for the
+// shell or its template to expand environment variables in.
+func isCommandTemplateKey(key string) bool {
+	return key == "formatter_cmd" || isScopeKey(key, scopeCommandTemplateKeys)
+}
+
+// Whether a key is one of the given settings (or inside of one) of
+// any scope.
+func isScopeKey(key string, settings []string) bool {
+	rest, ok := strings.CutPrefix(key, "scopes.")
+	if !ok {
+		return false
+	}
+
+	// Scope names can contain dots, so match the setting name
+	// from the end of the key instead.
+	for _, k := range settings {
+		if strings.HasSuffix(rest, "."+k) || strings.Contains(rest, "."+k+".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"go.yaml.in/yaml/v3"
+)
+
+// Flattened configuration values after merging all configuration
+// files, keyed by their full key (i.e. `scopes.nixos.evaluator`).
+// This includes default values for top-level settings that were
+// not set in any file.
+func (c *Config) Values() map[string]any {
+	values := defaultValues()
+	maps.Copy(values, c.values)
+	return values
+}
+
+// Configuration files that were loaded, in order of lowest to
+// highest priority.
+func (c *Config) Locations() []string {
+	return c.locations
+}
+
+// Flattened default values of top-level settings.
+func defaultValues() map[string]any {
+	values := make(map[string]any)
+
+	v := reflect.ValueOf(NewConfig()).Elem()
+	t := v.Type()
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		key := field.Tag.Get("koanf")
+		if key == "" || key == "-" || !field.IsExported() {
+			continue
+		}
+
+		// Scopes have no defaults, and are only present if set.
+		// Includes are only used while loading files.
+		if field.Type.Kind() == reflect.Map || key == "include" {
+			continue
+		}
+
+		values[key] = v.Field(i).Interface()
+	}
+
+	return values
+}
+
+// Format a configuration value as it would be written in TOML.
+func FormatValue(v any) string {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Parse a value passed on the command line as a TOML value, such as
+// `3`, `true`, or `["a", "b"]`. Anything that is not a valid TOML
+// value is treated as a plain string.
+func ParseValue(raw string) any {
+	m, err := toml.Parser().Unmarshal([]byte("v = " + raw))
+	if err != nil {
+		return raw
+	}
+	return m["v"]
+}
+
+// Set a key in a configuration file, and return the new contents of
+// the file without writing it. The file does not have to exist yet,
+// and is written in the format its extension indicates.
+//
+// Comments and formatting in the original file are not preserved;
+// use HasComments to check for comments first.
+func SetFileValue(path string, key string, value any) ([]byte, error) {
+	parser := ParserFor(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var m map[string]any
+	if len(bytes.TrimSpace(data)) > 0 {
+		m, err = parser.Unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+		}
+	}
+	if m == nil {
+		m = make(map[string]any)
+	}
+
+	segments := strings.Split(key, ".")
+	current := m
+
+	for i, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment]
+		if !ok {
+			table := make(map[string]any)
+			current[segment] = table
+			current = table
+			continue
+		}
+
+		table, ok := next.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot set %v, since %v is not a table", key, strings.Join(segments[:i+1], "."))
+		}
+		current = table
+	}
+
+	current[segments[len(segments)-1]] = value
+
+	return parser.Marshal(m)
+}
+
+// Convert a scope to the values that would be written in a
+// configuration file for it, omitting settings that are not set.
+func ScopeValues(s Scope) map[string]any {
+	values := make(map[string]any)
+
+	v := reflect.ValueOf(s)
+	t := v.Type()
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		key := field.Tag.Get("koanf")
+		if key == "" || key == "-" || !field.IsExported() || v.Field(i).IsZero() {
+			continue
+		}
+
+		switch value := v.Field(i).Interface().(type) {
+		case Command:
+			if value.IsArgv() {
+				values[key] = value.Argv
+			} else {
+				values[key] = value.Shell
+			}
+		default:
+			values[key] = value
+		}
+	}
+
+	return values
+}
+
+// Whether a configuration file has any comments, which SetFileValue
+// does not preserve. JSON files cannot have comments.
+func HasComments(path string, data []byte) (bool, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return false, nil
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return false, err
+		}
+		return yamlHasComments(&doc), nil
+	default:
+		p := unstable.Parser{KeepComments: true}
+		p.Reset(data)
+		for p.NextExpression() {
+			if tomlHasComments(p.Expression()) {
+				return true, nil
+			}
+		}
+		return false, p.Error()
+	}
+}
+
+func yamlHasComments(n *yaml.Node) bool {
+	if n.HeadComment != "" || n.LineComment != "" || n.FootComment != "" {
+		return true
+	}
+
+	return slices.ContainsFunc(n.Content, yamlHasComments)
+}
+
+// Comments after a value are chained to it, and comments inside of
+// arrays are their children, so the whole tree needs to be searched.
+func tomlHasComments(n *unstable.Node) bool {
+	for ; n != nil; n = n.Next() {
+		if n.Kind == unstable.Comment || tomlHasComments(n.Child()) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+)
+
+type Op int
+
+const (
+	OpEqual Op = iota
+	OpDelete
+	OpInsert
+)
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Compute a line-based diff that transforms a into b, using
+// Myers' O(ND) algorithm for finding the shortest edit script.
+func Lines(a, b string) []Line {
+	return diff(splitLines(a), splitLines(b))
+}
+
+// Whether or not a diff contains any insertions or deletions.
+func HasChanges(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != OpEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func diff(a, b []string) []Line {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+
+	// v[offset+k] holds the furthest x position reached on
+	// diagonal k. For every edit distance d, the diagonals that
+	// can be reached with it (and their neighbours) are kept so
+	// that the path can be reconstructed afterwards, which only
+	// needs O(D²) space rather than a full copy of v each time.
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+search:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var lines []Line
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// The window for d starts at diagonal -d-1
+		v := trace[d]
+		at := func(k int) int {
+			return v[k+d+1]
+		}
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, Line{Op: OpEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			lines = append(lines, Line{Op: OpInsert, Text: b[y-1]})
+			y--
+		} else {
+			lines = append(lines, Line{Op: OpDelete, Text: a[x-1]})
+			x--
+		}
+	}
+
+	slices.Reverse(lines)
+
+	return lines
+}
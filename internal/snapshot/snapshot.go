@@ -0,0 +1,169 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A set of option values recorded from a scope at a point in time.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	// Option names and prefixes that were requested when saving
+	Selectors []string `json:"selectors,omitempty"`
+	// Evaluated values, keyed by option name
+	Values map[string]Value `json:"values"`
+}
+
+type Value struct {
+	Value string `json:"value,omitempty"`
+	// Evaluation error message, if the option could not be evaluated
+	Error string `json:"error,omitempty"`
+}
+
+var ErrNotFound = errors.New("snapshot not found")
+
+// Directory where snapshots are stored, depending on
+// `XDG_STATE_HOME` presence.
+func Dir() (string, error) {
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); xdgStateHome != "" {
+		return filepath.Join(xdgStateHome, "optnix", "snapshots"), nil
+	}
+
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("neither $XDG_STATE_HOME nor $HOME are set")
+	}
+
+	return filepath.Join(home, ".local", "state", "optnix", "snapshots"), nil
+}
+
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("snapshot name must not be empty")
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid snapshot name '%v'", name)
+	}
+	return nil
+}
+
+func Path(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+".json"), nil
+}
+
+func Load(name string) (*Snapshot, error) {
+	path, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, name)
+		}
+		return nil, err
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %v: %w", path, err)
+	}
+
+	return &s, nil
+}
+
+// Write a snapshot to the snapshot directory, replacing any existing
+// snapshot with the same name. Returns the path it was written to.
+func Save(s *Snapshot) (string, error) {
+	path, err := Path(s.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first, so that an interrupted save
+	// does not leave a truncated snapshot behind.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// List the names of all saved snapshots.
+func List() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Option names in this snapshot, in sorted order.
+func (s *Snapshot) OptionNames() []string {
+	names := make([]string, 0, len(s.Values))
+	for name := range s.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -24,9 +24,19 @@ type ShellExecOutput struct {
 	Stderr string
 }
 
-func ExecShellAndCaptureOutput(commandStr string) (ShellExecOutput, error) {
-	cmd := exec.Command("/bin/sh", "-c", commandStr)
-	cmd.Env = os.Environ()
+type ExecOptions struct {
+	// Working directory to run the command in; if empty, the
+	// current directory is used.
+	Dir string
+	// Environment of the command, in `KEY=value` form
+	Env []string
+}
+
+// Run a command directly, without a shell, and capture its output.
+func ExecAndCaptureOutput(argv []string, opts ExecOptions) (ShellExecOutput, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.Env
 
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
@@ -42,5 +52,11 @@ func ExecShellAndCaptureOutput(commandStr string) (ShellExecOutput, error) {
 	result.Stdout = stdout.String()
 	result.Stderr = stderr.String()
 
+	// Make sure that errors such as a missing executable are not
+	// silently swallowed by callers that only look at stderr.
+	if err != nil && cmd.ProcessState == nil && result.Stderr == "" {
+		result.Stderr = err.Error()
+	}
+
 	return result, err
 }
@@ -27,6 +27,10 @@ pkgs: let
   Combine together multiple option lists that were created using
   any of the `mkOptionsList*` functions defined in this library.
 
+  Scopes can also merge several option lists themselves through
+  their `sources` setting, which resolves duplicate options and
+  records which list each option came from.
+
   @param  lists  list of options.json list derivations
   @return        combined options.json file
   */
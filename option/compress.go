@@ -0,0 +1,53 @@
+package option
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// Wrap a reader so that it decompresses its data if it starts with
+// the magic bytes of a known compression format, and passes it
+// through as-is otherwise.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	// Short inputs cannot be compressed, and are left for the JSON
+	// decoder to report errors on.
+	header, _ := br.Peek(len(xzMagic))
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return zr, nil
+	case bytes.HasPrefix(header, zstdMagic):
+		// Decoding synchronously avoids leaving goroutines behind.
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd data: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case bytes.HasPrefix(header, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read xz data: %w", err)
+		}
+		return io.NopCloser(xr), nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
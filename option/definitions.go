@@ -0,0 +1,65 @@
+package option
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A location where an option is defined in a configuration,
+// along with the value that it contributed.
+type OptionDefinition struct {
+	File string `json:"file"`
+	// Value contributed by this definition, as a Nix expression
+	Value string `json:"value"`
+	// Priority of this definition, if known; lower values take
+	// precedence, such as 50 for `lib.mkForce`.
+	Priority *int `json:"priority,omitempty"`
+}
+
+type DefinitionsFunc func(optionName string, location []string) ([]OptionDefinition, error)
+
+// Parse a JSON list of definitions, such as the output of
+// `nix eval --json` on `options.<path>.definitionsWithLocations`,
+// with `options.<path>.highestPrio` added to each one as `priority`.
+//
+// Each element must have a `file` and `value` attribute, and can
+// optionally have a `priority` attribute. Values are converted
+// to Nix expressions.
+func ParseOptionDefinitions(data string) ([]OptionDefinition, error) {
+	var raw []struct {
+		File     string          `json:"file"`
+		Value    json.RawMessage `json:"value"`
+		Priority *int            `json:"priority"`
+	}
+
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, err
+	}
+
+	definitions := make([]OptionDefinition, len(raw))
+	for i, r := range raw {
+		value := strings.TrimSpace(string(r.Value))
+		if nixValue, err := JSONToNix(value); err == nil {
+			value = nixValue
+		}
+
+		definitions[i] = OptionDefinition{
+			File:     r.File,
+			Value:    value,
+			Priority: r.Priority,
+		}
+	}
+
+	return definitions, nil
+}
+
+// Describe an error from finding definitions, including the output
+// of the definitions command if it failed to run.
+func DefinitionsErrorText(err error) string {
+	if e, ok := err.(*AttributeEvaluationError); ok && e.EvaluationOutput != "" {
+		return "failed to find definitions: " + e.EvaluationOutput
+	}
+
+	return fmt.Sprintf("failed to find definitions: %v", err)
+}
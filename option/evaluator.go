@@ -2,7 +2,24 @@ package option
 
 import "fmt"
 
-type EvaluatorFunc func(optionName string) (string, error)
+// Evaluates an option, given its name and attribute names (i.e. its
+// `loc`). The location can be empty if it is not known, such as for
+// option names given on the command line.
+type EvaluatorFunc func(optionName string, location []string) (string, error)
+
+// Evaluates several options at once, returning the value of each
+// one as JSON, in the same order as the given names.
+type BatchEvaluatorFunc func(optionNames []string, locations [][]string) ([]string, error)
+
+// The format of values returned by an evaluator.
+type EvaluatorOutput string
+
+const (
+	// Nix expressions, as printed by `nix eval` or `nix-instantiate --eval`.
+	EvaluatorOutputNix EvaluatorOutput = "nix"
+	// JSON documents, as printed by `nix eval --json`.
+	EvaluatorOutputJSON EvaluatorOutput = "json"
+)
 
 type AttributeEvaluationError struct {
 	Attribute        string
@@ -0,0 +1,322 @@
+package option
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type OutputFormat string
+
+const (
+	// The name of the option, and nothing else.
+	OutputFormatName OutputFormat = "name"
+	// A Nix assignment using a dotted attribute path,
+	// i.e. `services.nginx.enable = true;`.
+	OutputFormatNix OutputFormat = "nix"
+	// A Nix assignment inside of a nested attribute set.
+	OutputFormatNixAttrset OutputFormat = "nix-attrset"
+	// A JSON record containing all known information about the option.
+	OutputFormatJSON OutputFormat = "json"
+	// A Markdown snippet with the description, type, and values.
+	OutputFormatMarkdown OutputFormat = "markdown"
+)
+
+var OutputFormats = []OutputFormat{
+	OutputFormatName,
+	OutputFormatNix,
+	OutputFormatNixAttrset,
+	OutputFormatJSON,
+	OutputFormatMarkdown,
+}
+
+func (f OutputFormat) Description() string {
+	switch f {
+	case OutputFormatName:
+		return "Option name"
+	case OutputFormatNix:
+		return "Nix assignment"
+	case OutputFormatNixAttrset:
+		return "Nix assignment (nested attrset)"
+	case OutputFormatJSON:
+		return "JSON record"
+	case OutputFormatMarkdown:
+		return "Markdown snippet"
+	default:
+		return string(f)
+	}
+}
+
+var ErrNoValueAvailable = errors.New("option has no value, example, or default to use")
+
+// Format an option using the given output format.
+//
+// The value is the evaluated value of the option, if available. For
+// formats that require a value, the example and default values of the
+// option will be used in that order if there is no evaluated value.
+func (o *NixosOption) Format(format OutputFormat, value *string) (string, error) {
+	switch format {
+	case OutputFormatName:
+		return o.Name, nil
+	case OutputFormatNix:
+		v, err := o.assignableValue(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v = %v;", FormatAttrPath(o.attrPath()), v), nil
+	case OutputFormatNixAttrset:
+		v, err := o.assignableValue(value)
+		if err != nil {
+			return "", err
+		}
+		return o.formatNestedAttrset(v), nil
+	case OutputFormatJSON:
+		return o.FormatJSON(value, nil)
+	case OutputFormatMarkdown:
+		return o.formatMarkdown(value), nil
+	default:
+		return "", fmt.Errorf("unknown output format '%v'", format)
+	}
+}
+
+type optionJSONRecord struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Type         string   `json:"type"`
+	Value        *string  `json:"value"`
+	Default      string   `json:"default"`
+	Example      string   `json:"example"`
+	Location     []string `json:"loc"`
+	ReadOnly     bool     `json:"readOnly"`
+	Declarations []string `json:"declarations"`
+	Source       string   `json:"source,omitempty"`
+
+	Definitions []OptionDefinition `json:"definitions,omitempty"`
+}
+
+// Format an option as a JSON record, including the locations it
+// is defined at in the configuration, if available.
+func (o *NixosOption) FormatJSON(value *string, definitions []OptionDefinition) (string, error) {
+	defaultText := ""
+	if o.Default != nil {
+		defaultText = o.Default.Text
+	}
+
+	exampleText := ""
+	if o.Example != nil {
+		exampleText = o.Example.Text
+	}
+
+	bytes, err := json.MarshalIndent(optionJSONRecord{
+		Name:         o.Name,
+		Description:  o.Description,
+		Type:         o.Type,
+		Value:        value,
+		Default:      defaultText,
+		Example:      exampleText,
+		Location:     o.Location,
+		ReadOnly:     o.ReadOnly,
+		Declarations: o.Declarations,
+		Source:       o.Source,
+		Definitions:  definitions,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+func (o *NixosOption) formatNestedAttrset(value string) string {
+	path := o.attrPath()
+
+	var sb strings.Builder
+
+	sb.WriteString("{\n")
+
+	for i, attr := range path[:len(path)-1] {
+		indent := strings.Repeat("  ", i+1)
+		fmt.Fprintf(&sb, "%v%v = {\n", indent, FormatAttrPath([]string{attr}))
+	}
+
+	innerIndent := strings.Repeat("  ", len(path))
+	fmt.Fprintf(&sb, "%v%v = %v;\n", innerIndent, FormatAttrPath(path[len(path)-1:]), indentLines(value, innerIndent))
+
+	for i := len(path) - 1; i > 0; i-- {
+		fmt.Fprintf(&sb, "%v};\n", strings.Repeat("  ", i))
+	}
+
+	sb.WriteString("}")
+
+	return sb.String()
+}
+
+func (o *NixosOption) formatMarkdown(value *string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "## `%s`\n\n", o.Name)
+
+	if desc := strings.TrimSpace(stripInlineCodeAnnotations(o.Description)); desc != "" {
+		sb.WriteString(desc + "\n\n")
+	}
+
+	fmt.Fprintf(&sb, "**Type:** `%s`\n\n", o.Type)
+
+	if value != nil {
+		sb.WriteString(markdownValue("Value", *value))
+	}
+
+	if o.Default != nil {
+		sb.WriteString(markdownValue("Default", o.Default.Text))
+	}
+
+	if o.Example != nil {
+		sb.WriteString(markdownValue("Example", o.Example.Text))
+	}
+
+	if o.ReadOnly {
+		sb.WriteString("_This option is read-only._\n\n")
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+func markdownValue(title string, value string) string {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "\n") {
+		return fmt.Sprintf("**%s:** `%s`\n\n", title, value)
+	}
+
+	return fmt.Sprintf("**%s:**\n\n```nix\n%s\n```\n\n", title, value)
+}
+
+// Determine the value to use on the right-hand side of a Nix
+// assignment for this option.
+func (o *NixosOption) assignableValue(value *string) (string, error) {
+	if value != nil {
+		if v := strings.TrimSpace(*value); v != "" {
+			return v, nil
+		}
+	}
+
+	for _, v := range []*NixosOptionValue{o.Example, o.Default} {
+		if v == nil || v.Type == "literalMD" {
+			continue
+		}
+
+		if text := strings.TrimSpace(v.Text); text != "" {
+			return text, nil
+		}
+	}
+
+	return "", ErrNoValueAvailable
+}
+
+func (o *NixosOption) attrPath() []string {
+	if len(o.Location) > 0 {
+		return o.Location
+	}
+
+	return SplitOptionName(o.Name)
+}
+
+// Split an option name into its attribute names, the inverse of
+// FormatAttrPath. Dots inside of quoted attribute names (such as in
+// `boot.loader."systemd-boot".enable`) do not split them, and quoted
+// names are unescaped.
+func SplitOptionName(name string) []string {
+	var path []string
+	var sb strings.Builder
+
+	quoted := false
+	escaped := false
+
+	for _, r := range name {
+		switch {
+		case escaped:
+			switch r {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				sb.WriteRune(r)
+			}
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && r == '.':
+			path = append(path, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return append(path, sb.String())
+}
+
+var (
+	nixIdentifierPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_'-]*$`)
+	nixKeywords          = []string{
+		"assert", "else", "if", "in", "inherit", "let", "or", "rec", "then", "with",
+	}
+)
+
+// Construct a Nix attribute path from a list of attribute names,
+// quoting any names that are not valid Nix identifiers.
+func FormatAttrPath(path []string) string {
+	quoted := make([]string, len(path))
+
+	for i, attr := range path {
+		if nixIdentifierPattern.MatchString(attr) && !slices.Contains(nixKeywords, attr) {
+			quoted[i] = attr
+		} else {
+			quoted[i] = quoteNixString(attr)
+		}
+	}
+
+	return strings.Join(quoted, ".")
+}
+
+func quoteNixString(s string) string {
+	var sb strings.Builder
+
+	sb.WriteByte('"')
+	for i, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '$':
+			// Prevent accidental string interpolation.
+			if i+1 < len(s) && s[i+1] == '{' {
+				sb.WriteString(`\$`)
+			} else {
+				sb.WriteRune(r)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// Indent every line but the first with the given prefix,
+// so that multi-line values line up with an assignment.
+func indentLines(s string, prefix string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
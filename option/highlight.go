@@ -0,0 +1,105 @@
+package option
+
+import (
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var (
+	nixCommentStyle    = color.New(color.FgHiBlack, color.Italic)
+	nixStringStyle     = color.New(color.FgGreen)
+	nixInterpStyle     = color.New(color.FgCyan, color.Bold)
+	nixPathStyle       = color.New(color.FgCyan)
+	nixAnnotationStyle = color.New(color.FgHiBlack, color.Italic)
+	nixNumberStyle     = color.New(color.FgYellow)
+	nixConstantStyle   = color.New(color.FgYellow)
+	nixKeywordStyle    = color.New(color.FgMagenta, color.Bold)
+	nixAttrNameStyle   = color.New(color.FgBlue)
+	nixDefaultStyle    = color.New(color.FgWhite)
+)
+
+func highlightingDisabled() bool {
+	return color.NoColor || os.Getenv("NO_COLOR") != ""
+}
+
+// Highlight Nix code using ANSI escape codes for display in a
+// terminal. This is a no-op if color output is disabled, such as
+// when `NO_COLOR` is set.
+func HighlightNix(code string) string {
+	if highlightingDisabled() {
+		return code
+	}
+
+	tokens := lexNix(code)
+
+	var sb strings.Builder
+
+	for i, t := range tokens {
+		var style *color.Color
+
+		switch t.Kind {
+		case nixTokenWhitespace:
+			sb.WriteString(t.Text)
+			continue
+		case nixTokenComment:
+			style = nixCommentStyle
+		case nixTokenString, nixTokenURI:
+			style = nixStringStyle
+		case nixTokenInterpStart, nixTokenInterpEnd:
+			style = nixInterpStyle
+		case nixTokenPath, nixTokenSearchPath:
+			style = nixPathStyle
+		case nixTokenAnnotation:
+			style = nixAnnotationStyle
+		case nixTokenNumber:
+			style = nixNumberStyle
+		case nixTokenKeyword:
+			style = nixKeywordStyle
+		case nixTokenIdentifier:
+			switch {
+			case t.Text == "true" || t.Text == "false" || t.Text == "null":
+				style = nixConstantStyle
+			case isAttrName(tokens, i):
+				style = nixAttrNameStyle
+			default:
+				style = nixDefaultStyle
+			}
+		default:
+			style = nixDefaultStyle
+		}
+
+		sb.WriteString(colorizeLines(style, t.Text))
+	}
+
+	return sb.String()
+}
+
+// Determine if an identifier is used as an attribute name in a
+// binding, i.e. is followed by `=` or `.` (as in `a.b = 1;`).
+//
+// Attribute selections such as `pkgs.hello` are also highlighted
+// this way, which is fine for display purposes.
+func isAttrName(tokens []nixToken, i int) bool {
+	for _, t := range tokens[i+1:] {
+		if t.Kind == nixTokenWhitespace || t.Kind == nixTokenComment {
+			continue
+		}
+		return t.Kind == nixTokenPunct && (t.Text == "=" || t.Text == ".")
+	}
+	return false
+}
+
+// Color each line of the text separately, so that the escape codes
+// do not span multiple lines. Viewports and other line-based displays
+// will lose track of the active color otherwise.
+func colorizeLines(style *color.Color, text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = style.Sprint(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,59 @@
+package option
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Convert a JSON document, such as one printed by `nix eval --json`,
+// into an equivalent Nix expression.
+func JSONToNix(value string) (string, error) {
+	d := json.NewDecoder(strings.NewReader(value))
+	d.UseNumber()
+
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+
+	return printNixValue(jsonToNixValue(v), DefaultFormatterIndent, DefaultFormatterWidth), nil
+}
+
+func jsonToNixValue(v any) *nixValue {
+	switch v := v.(type) {
+	case nil:
+		return &nixValue{Kind: nixValueAtom, Text: "null"}
+	case bool:
+		return &nixValue{Kind: nixValueAtom, Text: fmt.Sprintf("%v", v)}
+	case json.Number:
+		return &nixValue{Kind: nixValueAtom, Text: v.String()}
+	case string:
+		return &nixValue{Kind: nixValueAtom, Text: quoteNixString(v)}
+	case []any:
+		list := &nixValue{Kind: nixValueList}
+		for _, elem := range v {
+			list.Items = append(list.Items, jsonToNixValue(elem))
+		}
+		return list
+	case map[string]any:
+		// Nix attribute sets are always sorted by name.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		attrs := &nixValue{Kind: nixValueAttrs}
+		for _, k := range keys {
+			attrs.Bindings = append(attrs.Bindings, nixBinding{
+				Name:  FormatAttrPath([]string{k}),
+				Value: jsonToNixValue(v[k]),
+			})
+		}
+		return attrs
+	}
+
+	return &nixValue{Kind: nixValueAtom, Text: fmt.Sprintf("%v", v)}
+}
@@ -0,0 +1,300 @@
+package option
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type nixTokenKind int
+
+const (
+	nixTokenWhitespace nixTokenKind = iota
+	nixTokenComment
+	// A fragment of a string literal; this includes the delimiters
+	// and escape sequences as they appear in the source.
+	nixTokenString
+	// `${`, either inside of a string or as a dynamic attribute.
+	nixTokenInterpStart
+	// `}` that closes an interpolation.
+	nixTokenInterpEnd
+	nixTokenPath
+	// Search paths, such as <nixpkgs>. `nix-instantiate` also prints
+	// values such as <LAMBDA> and <CODE> using this syntax.
+	nixTokenSearchPath
+	nixTokenURI
+	// Annotations printed by `nix eval`, such as «lambda» or «repeated».
+	nixTokenAnnotation
+	nixTokenNumber
+	nixTokenIdentifier
+	nixTokenKeyword
+	nixTokenOperator
+	nixTokenPunct
+	nixTokenInvalid
+)
+
+type nixToken struct {
+	Kind nixTokenKind
+	Text string
+}
+
+var (
+	nixPathRegex       = regexp.MustCompile(`^(?:~|[a-zA-Z0-9._+-]*)(?:/[a-zA-Z0-9._+-]+)+/?`)
+	nixSearchPathRegex = regexp.MustCompile(`^<[a-zA-Z0-9._+-]+(?:/[a-zA-Z0-9._+-]+)*>`)
+	nixURIRegex        = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:[a-zA-Z0-9%/?:@&=+$,_.!~*'-]+`)
+	nixNumberRegex     = regexp.MustCompile(`^[0-9]+(?:\.[0-9]*)?(?:[eE][+-]?[0-9]+)?`)
+	nixIdentRegex      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_'-]*`)
+)
+
+var nixOperators = []string{
+	"...", "==", "!=", "<=", ">=", "&&", "||", "->", "//", "++",
+	"+", "-", "*", "/", "<", ">", "!", "?",
+}
+
+const nixPunctuation = "{}[]();=:,.@"
+
+type nixLexerMode int
+
+const (
+	nixLexerModeNormal nixLexerMode = iota
+	nixLexerModeString
+	nixLexerModeIndentedString
+)
+
+type nixLexerFrame struct {
+	mode nixLexerMode
+	// Nesting depth of braces, used for finding the closing
+	// brace of an interpolation in normal mode.
+	depth int
+	// Whether or not this frame was started by an interpolation.
+	interp bool
+}
+
+// Split a Nix expression into tokens.
+//
+// This is a lenient lexer; it will never fail, and any characters
+// it does not understand are returned as invalid tokens. Joining the
+// text of all returned tokens will always yield the original input.
+func lexNix(src string) []nixToken {
+	var tokens []nixToken
+
+	stack := []nixLexerFrame{{mode: nixLexerModeNormal}}
+	pos := 0
+
+	emit := func(kind nixTokenKind, length int) {
+		tokens = append(tokens, nixToken{Kind: kind, Text: src[pos : pos+length]})
+		pos += length
+	}
+
+	for pos < len(src) {
+		top := &stack[len(stack)-1]
+		rest := src[pos:]
+
+		switch top.mode {
+		case nixLexerModeString, nixLexerModeIndentedString:
+			length, done, interp := scanStringFragment(rest, top.mode)
+			if length > 0 {
+				emit(nixTokenString, length)
+			}
+
+			if done {
+				stack = stack[:len(stack)-1]
+			} else if interp {
+				emit(nixTokenInterpStart, 2)
+				stack = append(stack, nixLexerFrame{mode: nixLexerModeNormal, interp: true})
+			}
+
+			continue
+		}
+
+		c := rest[0]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			n := len(rest) - len(strings.TrimLeft(rest, " \t\r\n"))
+			emit(nixTokenWhitespace, n)
+
+		case c == '#':
+			n := strings.IndexByte(rest, '\n')
+			if n == -1 {
+				n = len(rest)
+			}
+			emit(nixTokenComment, n)
+
+		case strings.HasPrefix(rest, "/*"):
+			n := strings.Index(rest[2:], "*/")
+			if n == -1 {
+				n = len(rest)
+			} else {
+				n += 4
+			}
+			emit(nixTokenComment, n)
+
+		case c == '"':
+			stack = append(stack, nixLexerFrame{mode: nixLexerModeString})
+			// The opening quote is included in the first fragment.
+			length, done, interp := scanStringFragment(rest[1:], nixLexerModeString)
+			emit(nixTokenString, length+1)
+			if done {
+				stack = stack[:len(stack)-1]
+			} else if interp {
+				emit(nixTokenInterpStart, 2)
+				stack = append(stack, nixLexerFrame{mode: nixLexerModeNormal, interp: true})
+			}
+
+		case strings.HasPrefix(rest, "''"):
+			stack = append(stack, nixLexerFrame{mode: nixLexerModeIndentedString})
+			length, done, interp := scanStringFragment(rest[2:], nixLexerModeIndentedString)
+			emit(nixTokenString, length+2)
+			if done {
+				stack = stack[:len(stack)-1]
+			} else if interp {
+				emit(nixTokenInterpStart, 2)
+				stack = append(stack, nixLexerFrame{mode: nixLexerModeNormal, interp: true})
+			}
+
+		case strings.HasPrefix(rest, "«"):
+			emit(nixTokenAnnotation, scanAnnotation(rest))
+
+		case strings.HasPrefix(rest, "${"):
+			emit(nixTokenInterpStart, 2)
+			stack = append(stack, nixLexerFrame{mode: nixLexerModeNormal, interp: true})
+
+		case c == '{':
+			top.depth++
+			emit(nixTokenPunct, 1)
+
+		case c == '}':
+			if top.interp && top.depth == 0 {
+				emit(nixTokenInterpEnd, 1)
+				stack = stack[:len(stack)-1]
+			} else {
+				top.depth = max(top.depth-1, 0)
+				emit(nixTokenPunct, 1)
+			}
+
+		default:
+			kind, n := scanNixAtom(rest)
+			emit(kind, n)
+		}
+	}
+
+	return tokens
+}
+
+// Scan a string fragment, until either the end of the string or the
+// start of an interpolation. The returned length includes the closing
+// delimiter if the string ended.
+func scanStringFragment(s string, mode nixLexerMode) (length int, done bool, interp bool) {
+	i := 0
+
+	for i < len(s) {
+		switch mode {
+		case nixLexerModeString:
+			switch {
+			case s[i] == '\\':
+				i += 2
+				continue
+			case s[i] == '"':
+				return i + 1, true, false
+			case strings.HasPrefix(s[i:], "$${"):
+				i += 3
+				continue
+			case strings.HasPrefix(s[i:], "${"):
+				return i, false, true
+			}
+
+		case nixLexerModeIndentedString:
+			switch {
+			case strings.HasPrefix(s[i:], "'''"), strings.HasPrefix(s[i:], "''$"):
+				i += 3
+				continue
+			case strings.HasPrefix(s[i:], "''\\"):
+				i += 4
+				continue
+			case strings.HasPrefix(s[i:], "''"):
+				return i + 2, true, false
+			case strings.HasPrefix(s[i:], "$${"):
+				i += 3
+				continue
+			case strings.HasPrefix(s[i:], "${"):
+				return i, false, true
+			}
+		}
+
+		i++
+	}
+
+	// Unterminated string; consume everything.
+	return len(s), true, false
+}
+
+// Scan an annotation such as «lambda @ /nix/store/...», accounting
+// for nested annotations.
+func scanAnnotation(s string) int {
+	depth := 0
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch r {
+		case '«':
+			depth++
+		case '»':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return len(s)
+}
+
+func scanNixAtom(s string) (nixTokenKind, int) {
+	if m := nixSearchPathRegex.FindString(s); m != "" {
+		return nixTokenSearchPath, len(m)
+	}
+
+	if m := nixPathRegex.FindString(s); m != "" {
+		return nixTokenPath, len(m)
+	}
+
+	if m := nixURIRegex.FindString(s); m != "" {
+		return nixTokenURI, len(m)
+	}
+
+	if m := nixNumberRegex.FindString(s); m != "" {
+		return nixTokenNumber, len(m)
+	}
+
+	if m := nixIdentRegex.FindString(s); m != "" {
+		if isNixKeyword(m) {
+			return nixTokenKeyword, len(m)
+		}
+		return nixTokenIdentifier, len(m)
+	}
+
+	for _, op := range nixOperators {
+		if strings.HasPrefix(s, op) {
+			return nixTokenOperator, len(op)
+		}
+	}
+
+	if strings.IndexByte(nixPunctuation, s[0]) != -1 {
+		return nixTokenPunct, 1
+	}
+
+	_, size := utf8.DecodeRuneInString(s)
+	return nixTokenInvalid, size
+}
+
+func isNixKeyword(s string) bool {
+	for _, k := range nixKeywords {
+		if s == k {
+			return true
+		}
+	}
+	return false
+}
@@ -20,6 +20,10 @@ type NixosOption struct {
 	Location     []string          `json:"loc"`
 	ReadOnly     bool              `json:"readOnly"`
 	Declarations []string          `json:"declarations"`
+
+	// Name of the source this option was loaded from, for scopes
+	// that merge options from several sources
+	Source string `json:"source,omitempty"`
 }
 
 type NixosOptionValue struct {
@@ -37,11 +41,19 @@ func (o NixosOptionSource) Len() int {
 	return len(o)
 }
 
+// Load an options list, which can be compressed with gzip, zstd, or
+// xz; the compression format is detected from the data itself.
 func LoadOptions(r io.Reader) (NixosOptionSource, error) {
+	rc, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+
 	var options []NixosOption
 
-	d := json.NewDecoder(r)
-	err := d.Decode(&options)
+	d := json.NewDecoder(rc)
+	err = d.Decode(&options)
 	if err != nil {
 		return nil, err
 	}
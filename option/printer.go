@@ -12,6 +12,9 @@ import (
 type ValuePrinterInput struct {
 	Value string
 	Err   error
+
+	Definitions    []OptionDefinition
+	DefinitionsErr error
 }
 
 func (o *NixosOption) PrettyPrint(value *ValuePrinterInput) string {
@@ -45,20 +48,20 @@ func (o *NixosOption) PrettyPrint(value *ValuePrinterInput) string {
 
 			valueText = color.RedString(valueText)
 		} else {
-			valueText = color.WhiteString(strings.TrimSpace(value.Value))
+			valueText = HighlightNix(strings.TrimSpace(value.Value))
 		}
 	}
 
 	var defaultText string
 	if o.Default != nil {
-		defaultText = color.WhiteString(strings.TrimSpace(o.Default.Text))
+		defaultText = o.Default.highlighted()
 	} else {
 		defaultText = italicStyle.Sprint("(none)")
 	}
 
 	exampleText := ""
 	if o.Example != nil {
-		exampleText = color.WhiteString(strings.TrimSpace(o.Example.Text))
+		exampleText = o.Example.highlighted()
 	}
 
 	fmt.Fprintf(&sb, "%v\n%v\n\n", titleStyle.Sprint("Name"), o.Name)
@@ -73,12 +76,29 @@ func (o *NixosOption) PrettyPrint(value *ValuePrinterInput) string {
 		fmt.Fprintf(&sb, "%v\n%v\n\n", titleStyle.Sprint("Example"), exampleText)
 	}
 
+	if o.Source != "" {
+		fmt.Fprintf(&sb, "%v\n%v\n\n", titleStyle.Sprint("Source"), italicStyle.Sprint(o.Source))
+	}
+
 	if len(o.Declarations) > 0 {
 		fmt.Fprintf(&sb, "%v\n", titleStyle.Sprint("Declared In"))
 		for _, v := range o.Declarations {
 			fmt.Fprintf(&sb, "  - %v\n", italicStyle.Sprint(v))
 		}
 	}
+
+	if value != nil && (value.DefinitionsErr != nil || len(value.Definitions) > 0) {
+		if len(o.Declarations) > 0 {
+			sb.WriteString("\n")
+		}
+
+		fmt.Fprintf(&sb, "%v\n", titleStyle.Sprint("Defined In"))
+		if value.DefinitionsErr != nil {
+			fmt.Fprintf(&sb, "%v\n", color.RedString(DefinitionsErrorText(value.DefinitionsErr)))
+		} else {
+			sb.WriteString(FormatDefinitions(value.Definitions))
+		}
+	}
 	if o.ReadOnly {
 		fmt.Fprintf(&sb, "\n%v\n", color.YellowString("This option is read-only."))
 	}
@@ -86,6 +106,40 @@ func (o *NixosOption) PrettyPrint(value *ValuePrinterInput) string {
 	return sb.String()
 }
 
+// Format a list of definitions for display, with each definition's
+// file, priority, and highlighted value.
+func FormatDefinitions(definitions []OptionDefinition) string {
+	var sb strings.Builder
+
+	italicStyle := color.New(color.Italic)
+
+	for _, d := range definitions {
+		fmt.Fprintf(&sb, "  - %v", italicStyle.Sprint(d.File))
+		if d.Priority != nil {
+			fmt.Fprintf(&sb, " %v", color.YellowString("(priority %d)", *d.Priority))
+		}
+		sb.WriteString("\n")
+
+		if d.Value != "" {
+			fmt.Fprintf(&sb, "    %v\n", indentLines(HighlightNix(d.Value), "    "))
+		}
+	}
+
+	return sb.String()
+}
+
+// Highlight the text of a default or example value. Only Nix
+// expressions are highlighted; Markdown values are left as-is.
+func (v *NixosOptionValue) highlighted() string {
+	text := strings.TrimSpace(v.Text)
+
+	if v.Type == "literalMD" {
+		return color.WhiteString(text)
+	}
+
+	return HighlightNix(text)
+}
+
 var (
 	markdownRenderIndentWidth uint = 0
 	renderer                       = NewMarkdownRenderer()
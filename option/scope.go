@@ -1,10 +1,83 @@
 package option
 
+import "errors"
+
 type OptionLoader func() (NixosOptionSource, error)
 
 type Scope struct {
 	Name        string
 	Description string
-	Loader      OptionLoader
-	Evaluator   EvaluatorFunc
+	// Heading to list this scope under, if any
+	Group     string
+	Loader    OptionLoader
+	Evaluator EvaluatorFunc
+	// Format of values returned by the evaluator; if empty,
+	// values are assumed to be Nix expressions.
+	EvaluatorOutput EvaluatorOutput
+	// Evaluates many options with a single command, which is much
+	// faster than running the evaluator for each one; this is
+	// optional.
+	BatchEvaluator BatchEvaluatorFunc
+	// Retrieves the locations an option is defined at in the
+	// configuration; this is optional.
+	Definitions DefinitionsFunc
+	// Names of parameters that need values before this scope can
+	// be loaded, if any.
+	Parameters []string
+	// Create a scope with values for its parameters, which are
+	// separated by commas (i.e. `web01` or `host=web01`).
+	Bind func(values string) (Scope, error)
+}
+
+// Whether or not this scope needs values for its parameters before
+// it can be loaded.
+func (s *Scope) NeedsParameters() bool {
+	return len(s.Parameters) > 0 && s.Bind != nil
+}
+
+var ErrNoEvaluator = errors.New("no evaluator configured for this scope")
+
+// Evaluate an option in this scope, and return its value as a Nix
+// expression. JSON values are converted to Nix expressions.
+func (s *Scope) EvaluateNix(optionName string, location []string) (string, error) {
+	if s.Evaluator == nil {
+		return "", ErrNoEvaluator
+	}
+
+	value, err := s.Evaluator(optionName, location)
+	if err != nil {
+		return "", err
+	}
+
+	if s.EvaluatorOutput == EvaluatorOutputJSON {
+		if nixValue, err := JSONToNix(value); err == nil {
+			value = nixValue
+		}
+	}
+
+	return value, nil
+}
+
+// Evaluate several options in this scope with its batch evaluator,
+// and return their values as Nix expressions, in the same order as
+// the given names.
+func (s *Scope) EvaluateNixBatch(optionNames []string, locations [][]string) ([]string, error) {
+	if s.BatchEvaluator == nil {
+		return nil, ErrNoEvaluator
+	}
+
+	values, err := s.BatchEvaluator(optionNames, locations)
+	if err != nil {
+		return nil, err
+	}
+
+	nixValues := make([]string, len(values))
+	for i, value := range values {
+		nixValues[i], err = JSONToNix(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nixValues, nil
 }
@@ -0,0 +1,414 @@
+package option
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultFormatterIndent = 2
+	DefaultFormatterWidth  = 80
+)
+
+// Settings for formatting values printed by `nix eval` or
+// `nix-instantiate --eval` for display.
+type NixValueFormatter struct {
+	// External formatter command to use, such as `nixfmt`; if
+	// empty, the built-in pretty-printer is used.
+	Cmd string
+	// Number of spaces to indent nested values with
+	Indent int
+	// Maximum line width to fit values inside of before
+	// breaking them up into multiple lines
+	Width int
+}
+
+// Format an evaluated value. This tries the external formatter
+// command first if one is configured, then the built-in printer,
+// and falls back to the original value if neither succeeds.
+func (f NixValueFormatter) Format(value string) string {
+	if f.Cmd != "" {
+		if formatted, err := FormatNixValue(f.Cmd, value); err == nil {
+			return formatted
+		}
+	}
+
+	if printed, err := PrettyPrintNixValue(value, f.Indent, f.Width); err == nil {
+		return printed
+	}
+
+	return value
+}
+
+type nixValueKind int
+
+const (
+	nixValueAtom nixValueKind = iota
+	nixValueAttrs
+	nixValueList
+)
+
+type nixBinding struct {
+	// Attribute path text, as it appeared in the source. Empty for
+	// elided attributes (`...`).
+	Name  string
+	Value *nixValue
+}
+
+// A parsed value printed by a Nix evaluator.
+type nixValue struct {
+	Kind nixValueKind
+	// Source text of atoms; this includes strings, numbers, paths,
+	// and annotations such as «lambda» or «repeated».
+	Text     string
+	Bindings []nixBinding
+	Items    []*nixValue
+}
+
+// Parse and pretty-print a value printed by `nix eval` or
+// `nix-instantiate --eval`, with the given indent size and
+// maximum line width.
+//
+// This only supports the subset of Nix syntax that evaluators
+// print, along with their annotations such as «lambda», «repeated»,
+// and elided attribute sets and lists like `{ ... }`.
+func PrettyPrintNixValue(value string, indent int, width int) (string, error) {
+	v, err := parseNixValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	return printNixValue(v, indent, width), nil
+}
+
+func parseNixValue(value string) (*nixValue, error) {
+	p := nixValueParser{}
+	for _, t := range lexNix(value) {
+		if t.Kind != nixTokenWhitespace && t.Kind != nixTokenComment {
+			p.tokens = append(p.tokens, t)
+		}
+	}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected trailing token '%v'", p.tokens[p.pos].Text)
+	}
+
+	return v, nil
+}
+
+// Lay out an evaluated value with every attribute and list element
+// on its own line, which makes line-based comparisons between values
+// meaningful. Values that cannot be parsed are returned as-is.
+func ExpandNixValue(value string, indent int) string {
+	if expanded, err := PrettyPrintNixValue(value, indent, 0); err == nil {
+		return expanded
+	}
+	return strings.TrimSpace(value)
+}
+
+// Determine if an evaluated value is the same as the default value
+// of this option. If the option has no default, any value counts as
+// a changed value.
+//
+// The returned ok value is false if the default cannot be compared,
+// such as when it is described with Markdown, or when it is an
+// arbitrary expression (i.e. `pkgs.hello`) instead of a plain value.
+func (o *NixosOption) IsDefaultValue(value string) (isDefault bool, ok bool) {
+	if o.Default == nil {
+		return false, true
+	}
+
+	if o.Default.Type == "literalMD" {
+		return false, false
+	}
+
+	defaultValue, err := parseNixValue(o.Default.Text)
+	if err != nil {
+		return false, false
+	}
+
+	evaluated, err := parseNixValue(value)
+	if err != nil {
+		return false, false
+	}
+
+	return normalizedNixValue(defaultValue) == normalizedNixValue(evaluated), true
+}
+
+// Print a value in a canonical form for comparisons, with all
+// attributes sorted by name like Nix does when printing them.
+func normalizedNixValue(v *nixValue) string {
+	var sortAttrs func(v *nixValue)
+	sortAttrs = func(v *nixValue) {
+		slices.SortStableFunc(v.Bindings, func(a, b nixBinding) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		for _, b := range v.Bindings {
+			if b.Value != nil {
+				sortAttrs(b.Value)
+			}
+		}
+		for _, item := range v.Items {
+			sortAttrs(item)
+		}
+	}
+	sortAttrs(v)
+
+	return flatNixValue(v)
+}
+
+func printNixValue(v *nixValue, indent int, width int) string {
+	pr := nixValuePrinter{indent: max(indent, 0), width: width}
+	pr.print(v, 0, 0, 0)
+	return pr.sb.String()
+}
+
+type nixValueParser struct {
+	tokens []nixToken
+	pos    int
+}
+
+func (p *nixValueParser) peek() *nixToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *nixValueParser) next() (nixToken, error) {
+	t := p.peek()
+	if t == nil {
+		return nixToken{}, fmt.Errorf("unexpected end of input")
+	}
+	p.pos++
+	return *t, nil
+}
+
+func (p *nixValueParser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.Text != text {
+		return fmt.Errorf("expected '%v', got '%v'", text, t.Text)
+	}
+	return nil
+}
+
+func (p *nixValueParser) parseValue() (*nixValue, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.Kind {
+	case nixTokenPunct:
+		switch t.Text {
+		case "{":
+			return p.parseAttrs()
+		case "[":
+			return p.parseList()
+		}
+
+	case nixTokenString:
+		return &nixValue{Kind: nixValueAtom, Text: t.Text}, nil
+
+	case nixTokenOperator:
+		switch t.Text {
+		case "...":
+			return &nixValue{Kind: nixValueAtom, Text: t.Text}, nil
+		case "-":
+			// Negative numbers are lexed as a separate operator.
+			if n := p.peek(); n != nil && n.Kind == nixTokenNumber {
+				p.pos++
+				return &nixValue{Kind: nixValueAtom, Text: "-" + n.Text}, nil
+			}
+		}
+
+	case nixTokenIdentifier, nixTokenNumber, nixTokenPath, nixTokenSearchPath,
+		nixTokenURI, nixTokenAnnotation:
+		return &nixValue{Kind: nixValueAtom, Text: t.Text}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected token '%v'", t.Text)
+}
+
+func (p *nixValueParser) parseAttrs() (*nixValue, error) {
+	v := &nixValue{Kind: nixValueAttrs}
+
+	for {
+		t := p.peek()
+		if t == nil {
+			return nil, fmt.Errorf("unterminated attribute set")
+		}
+
+		if t.Kind == nixTokenPunct && t.Text == "}" {
+			p.pos++
+			return v, nil
+		}
+
+		if t.Kind == nixTokenOperator && t.Text == "..." {
+			p.pos++
+			v.Bindings = append(v.Bindings, nixBinding{})
+			continue
+		}
+
+		name, err := p.parseAttrPath()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+
+		v.Bindings = append(v.Bindings, nixBinding{Name: name, Value: value})
+	}
+}
+
+func (p *nixValueParser) parseAttrPath() (string, error) {
+	var segments []string
+
+	for {
+		t, err := p.next()
+		if err != nil {
+			return "", err
+		}
+
+		switch t.Kind {
+		case nixTokenIdentifier, nixTokenKeyword, nixTokenString:
+			segments = append(segments, t.Text)
+		default:
+			return "", fmt.Errorf("unexpected token '%v' in attribute name", t.Text)
+		}
+
+		if n := p.peek(); n == nil || n.Kind != nixTokenPunct || n.Text != "." {
+			return strings.Join(segments, "."), nil
+		}
+		p.pos++
+	}
+}
+
+func (p *nixValueParser) parseList() (*nixValue, error) {
+	v := &nixValue{Kind: nixValueList}
+
+	for {
+		t := p.peek()
+		if t == nil {
+			return nil, fmt.Errorf("unterminated list")
+		}
+
+		if t.Kind == nixTokenPunct && t.Text == "]" {
+			p.pos++
+			return v, nil
+		}
+
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		v.Items = append(v.Items, item)
+	}
+}
+
+// A simple printer that keeps values on a single line if they
+// fit inside of the maximum width, and breaks them up otherwise.
+type nixValuePrinter struct {
+	sb     strings.Builder
+	indent int
+	width  int
+}
+
+// Print a value starting at the given column, with the given
+// nesting depth. Trailing is the width of any text that will be
+// printed on the same line after this value, such as a semicolon.
+func (pr *nixValuePrinter) print(v *nixValue, depth int, column int, trailing int) {
+	flat := flatNixValue(v)
+
+	empty := len(v.Bindings) == 0 && len(v.Items) == 0
+	if v.Kind == nixValueAtom || empty || column+utf8.RuneCountInString(flat)+trailing <= pr.width {
+		pr.sb.WriteString(flat)
+		return
+	}
+
+	inner := strings.Repeat(" ", (depth+1)*pr.indent)
+	outer := strings.Repeat(" ", depth*pr.indent)
+
+	switch v.Kind {
+	case nixValueAttrs:
+		pr.sb.WriteString("{\n")
+		for _, b := range v.Bindings {
+			pr.sb.WriteString(inner)
+			if b.Value == nil {
+				pr.sb.WriteString("...\n")
+				continue
+			}
+
+			prefix := b.Name + " = "
+			pr.sb.WriteString(prefix)
+			pr.print(b.Value, depth+1, len(inner)+utf8.RuneCountInString(prefix), 1)
+			pr.sb.WriteString(";\n")
+		}
+		pr.sb.WriteString(outer + "}")
+
+	case nixValueList:
+		pr.sb.WriteString("[\n")
+		for _, item := range v.Items {
+			pr.sb.WriteString(inner)
+			pr.print(item, depth+1, len(inner), 0)
+			pr.sb.WriteString("\n")
+		}
+		pr.sb.WriteString(outer + "]")
+	}
+}
+
+func flatNixValue(v *nixValue) string {
+	switch v.Kind {
+	case nixValueAttrs:
+		if len(v.Bindings) == 0 {
+			return "{ }"
+		}
+
+		var sb strings.Builder
+		sb.WriteString("{ ")
+		for _, b := range v.Bindings {
+			if b.Value == nil {
+				sb.WriteString("... ")
+				continue
+			}
+			fmt.Fprintf(&sb, "%s = %s; ", b.Name, flatNixValue(b.Value))
+		}
+		sb.WriteString("}")
+		return sb.String()
+
+	case nixValueList:
+		if len(v.Items) == 0 {
+			return "[ ]"
+		}
+
+		items := make([]string, len(v.Items))
+		for i, item := range v.Items {
+			items[i] = flatNixValue(item)
+		}
+		return "[ " + strings.Join(items, " ") + " ]"
+	}
+
+	return v.Text
+}
@@ -11,7 +11,7 @@ buildGoModule (finalAttrs: {
   version = "0.3.2-dev";
   src = nix-gitignore.gitignoreSource [] ./.;
 
-  vendorHash = "sha256-g/H91PiHWSRRQOkaobw2wAYX/07DFxWTCTlKzf7BT1Y=";
+  vendorHash = "sha256-9DNXCcGvDCOlsGdJAHRu6tp2k1nNuHhNp3ckBC9NyGg=";
 
   nativeBuildInputs = [installShellFiles scdoc];
 
@@ -0,0 +1,328 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"snare.dev/optnix/internal/diff"
+	"snare.dev/optnix/option"
+)
+
+var (
+	diffDeleteStyle = lipgloss.NewStyle().Foreground(ansiRed)
+	diffInsertStyle = lipgloss.NewStyle().Foreground(ansiGreen)
+)
+
+// A view for comparing the value of an option in the current
+// scope against its value in another scope.
+type CompareModel struct {
+	vp      viewport.Model
+	spinner spinner.Model
+
+	option string
+
+	current    option.Scope
+	candidates []option.Scope
+	selected   int
+
+	// Whether or not the scope to compare against is being picked
+	picking bool
+	loading bool
+
+	other   string
+	content string
+
+	// Indentation to use when expanding values to compare them
+	indent int
+
+	// View to return to after closing the compare view
+	returnMode ViewMode
+
+	width  int
+	height int
+}
+
+type CompareFinishedMsg struct {
+	Option string
+	Scope  string
+
+	CurrentValue string
+	CurrentErr   error
+	OtherValue   string
+	OtherErr     error
+}
+
+func NewCompareModel() CompareModel {
+	vp := viewport.New(0, 0)
+	vp.SetHorizontalStep(1)
+	vp.Style = focusedBorderStyle
+
+	sp := spinner.New()
+	sp.Spinner = spinner.Line
+	sp.Style = spinnerStyle
+
+	return CompareModel{
+		vp:      vp,
+		spinner: sp,
+		indent:  option.DefaultFormatterIndent,
+	}
+}
+
+func (m CompareModel) SetIndent(indent int) CompareModel {
+	m.indent = indent
+	return m
+}
+
+// Set the option to compare, along with the current scope and all
+// available scopes. This resets the view to picking a scope.
+func (m CompareModel) SetOption(o *option.NixosOption, current option.Scope, scopes []option.Scope) CompareModel {
+	m.option = o.Name
+	m.current = current
+
+	m.candidates = nil
+	for _, s := range scopes {
+		if s.Name != current.Name && !s.NeedsParameters() {
+			m.candidates = append(m.candidates, s)
+		}
+	}
+
+	m.selected = 0
+	m.picking = true
+	m.loading = false
+	m.content = ""
+
+	return m
+}
+
+func (m CompareModel) SetReturnMode(mode ViewMode) CompareModel {
+	m.returnMode = mode
+	return m
+}
+
+func (m CompareModel) Update(msg tea.Msg) (CompareModel, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc":
+			mode := m.returnMode
+			return m, func() tea.Msg {
+				return ChangeViewModeMsg(mode)
+			}
+		}
+
+		if m.picking {
+			switch msg.String() {
+			case "up", "k":
+				m.selected = max(m.selected-1, 0)
+			case "down", "j":
+				m.selected = min(m.selected+1, len(m.candidates)-1)
+			case "enter":
+				if len(m.candidates) == 0 {
+					break
+				}
+
+				m.picking = false
+				m.loading = true
+				m.other = m.candidates[m.selected].Name
+				m.vp.SetContent(m.constructLoadingContent())
+
+				return m, tea.Batch(m.compareCmd(m.candidates[m.selected]), m.spinner.Tick)
+			}
+
+			return m, nil
+		}
+
+		if msg.String() == "s" && !m.loading {
+			m.picking = true
+			return m, nil
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width - 4
+		m.height = msg.Height - 4
+
+		m.vp.Width = m.width
+		m.vp.Height = m.height
+
+		if !m.loading && m.content != "" {
+			m.vp.SetContent(m.content)
+		}
+
+		return m, nil
+
+	case CompareFinishedMsg:
+		if msg.Option != m.option || msg.Scope != m.other {
+			return m, nil
+		}
+
+		m.loading = false
+		m.content = m.constructDiffContent(msg)
+		m.vp.SetContent(m.content)
+		m.vp.GotoTop()
+
+		return m, nil
+
+	case spinner.TickMsg:
+		if !m.loading {
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		cmds = append(cmds, cmd)
+
+		m.vp.SetContent(m.constructLoadingContent())
+	}
+
+	var vpCmd tea.Cmd
+	m.vp, vpCmd = m.vp.Update(msg)
+	cmds = append(cmds, vpCmd)
+
+	return m, tea.Batch(cmds...)
+}
+
+// Evaluate the option in both scopes at the same time.
+func (m CompareModel) compareCmd(other option.Scope) tea.Cmd {
+	optionName := m.option
+	current := m.current
+
+	return func() tea.Msg {
+		result := CompareFinishedMsg{Option: optionName, Scope: other.Name}
+
+		// The location from the current scope's options list may not
+		// match the other scope, whose options are not loaded, so the
+		// location is derived from the name in both, like diff-value.
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.OtherValue, result.OtherErr = other.EvaluateNix(optionName, nil)
+		}()
+
+		result.CurrentValue, result.CurrentErr = current.EvaluateNix(optionName, nil)
+
+		wg.Wait()
+
+		return result
+	}
+}
+
+func (m CompareModel) constructHeader() string {
+	title := lipgloss.PlaceHorizontal(m.width, lipgloss.Left, titleStyle.Render(m.option))
+	line := lipgloss.NewStyle().Width(m.width).Inherit(titleRuleStyle).Render("")
+
+	return title + "\n" + line
+}
+
+func (m CompareModel) constructLoadingContent() string {
+	return m.constructHeader() + "\n" + fmt.Sprintf("Evaluating in '%v' and '%v'...", m.current.Name, m.other) + m.spinner.View()
+}
+
+func (m CompareModel) constructDiffContent(msg CompareFinishedMsg) string {
+	var sb strings.Builder
+
+	sb.WriteString(m.constructHeader() + "\n")
+
+	failed := false
+	for _, v := range []struct {
+		scope string
+		err   error
+	}{{m.current.Name, msg.CurrentErr}, {msg.Scope, msg.OtherErr}} {
+		if v.err == nil {
+			continue
+		}
+
+		failed = true
+
+		errStr := fmt.Sprintf("failed to evaluate in scope '%v': %v", v.scope, v.err)
+		if e, ok := v.err.(*option.AttributeEvaluationError); ok {
+			errStr += "\n\nevaluation trace:\n-----------------\n" + e.EvaluationOutput
+		}
+		sb.WriteString(evalErrorColor.Sprint(errStr) + "\n\n")
+	}
+
+	if failed {
+		return sb.String()
+	}
+
+	lines := diff.Lines(
+		option.ExpandNixValue(msg.CurrentValue, m.indent),
+		option.ExpandNixValue(msg.OtherValue, m.indent),
+	)
+
+	if !diff.HasChanges(lines) {
+		sb.WriteString(italicStyle.Render(fmt.Sprintf("This option has the same value in '%v' and '%v'.", m.current.Name, msg.Scope)))
+		return sb.String()
+	}
+
+	sb.WriteString(diffDeleteStyle.Bold(true).Render("--- "+m.current.Name) + "\n")
+	sb.WriteString(diffInsertStyle.Bold(true).Render("+++ "+msg.Scope) + "\n")
+
+	for _, l := range lines {
+		switch l.Op {
+		case diff.OpDelete:
+			sb.WriteString(diffDeleteStyle.Render("-" + l.Text))
+		case diff.OpInsert:
+			sb.WriteString(diffInsertStyle.Render("+" + l.Text))
+		default:
+			sb.WriteString(" " + l.Text)
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func (m CompareModel) View() string {
+	if !m.picking {
+		return m.vp.View()
+	}
+
+	// Account for the border on each side.
+	innerWidth := max(m.width-2, 0)
+	innerHeight := max(m.height-2, 0)
+
+	style := focusedBorderStyle.Width(innerWidth).Height(innerHeight)
+
+	title := lipgloss.PlaceHorizontal(innerWidth, lipgloss.Center, titleStyle.Render("Compare With Scope"))
+	line := lipgloss.NewStyle().Width(innerWidth).Inherit(titleRuleStyle).Render("")
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%v %v\n\n", boldStyle.Render(m.option), italicStyle.Render(fmt.Sprintf("(current scope: %v)", m.current.Name)))
+
+	if len(m.candidates) == 0 {
+		sb.WriteString(errorTextStyle.Render("There are no other scopes to compare against."))
+	}
+
+	for i, s := range m.candidates {
+		text := s.Name
+		if s.Description != "" {
+			text += " :: " + s.Description
+		}
+
+		if i == m.selected {
+			sb.WriteString(copyMenuSelectedItemStyle.Render(text))
+		} else {
+			sb.WriteString(copyMenuItemStyle.Render(text))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n" + hintStyle.Render("enter: compare, q/esc: close"))
+
+	body := lipgloss.NewStyle().
+		Width(innerWidth).
+		MaxHeight(max(innerHeight-2, 0)).
+		Render(sb.String())
+
+	return style.Render(title + "\n" + line + "\n" + body)
+}
@@ -0,0 +1,169 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"snare.dev/optnix/internal/clipboard"
+	"snare.dev/optnix/option"
+)
+
+var (
+	copyMenuItemStyle         = lipgloss.NewStyle().PaddingLeft(2)
+	copyMenuSelectedItemStyle = lipgloss.NewStyle().PaddingLeft(1).
+					Foreground(ansiGreen).
+					Border(lipgloss.NormalBorder(), false, false, false, true).
+					BorderForeground(ansiGreen)
+	copyMenuPreviewStyle = lipgloss.NewStyle().Foreground(ansiWhite)
+)
+
+type CopyMenuModel struct {
+	option *option.NixosOption
+	value  *string
+
+	formats  []option.OutputFormat
+	selected int
+
+	// View to return to after closing the menu
+	returnMode ViewMode
+
+	clipboardBackends []clipboard.Backend
+
+	width  int
+	height int
+}
+
+func NewCopyMenuModel() CopyMenuModel {
+	return CopyMenuModel{
+		formats:           option.OutputFormats,
+		clipboardBackends: clipboard.DefaultBackends,
+	}
+}
+
+// Set the option to generate copyable text for. The value is the
+// evaluated value of the option, if one is available.
+func (m CopyMenuModel) SetOption(o *option.NixosOption, value *string) CopyMenuModel {
+	m.option = o
+	m.value = value
+	m.selected = 0
+	return m
+}
+
+func (m CopyMenuModel) SetReturnMode(mode ViewMode) CopyMenuModel {
+	m.returnMode = mode
+	return m
+}
+
+func (m CopyMenuModel) SetClipboardBackends(backends []clipboard.Backend) CopyMenuModel {
+	m.clipboardBackends = backends
+	return m
+}
+
+func (m CopyMenuModel) Update(msg tea.Msg) (CopyMenuModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc":
+			return m, m.closeCmd()
+
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+
+		case "down", "j":
+			if m.selected < len(m.formats)-1 {
+				m.selected++
+			}
+
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			idx := int(msg.String()[0] - '1')
+			if idx < len(m.formats) {
+				m.selected = idx
+				return m, m.copySelectedCmd()
+			}
+
+		case "enter":
+			return m, m.copySelectedCmd()
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width - 4
+		m.height = msg.Height - 4
+	}
+
+	return m, nil
+}
+
+func (m CopyMenuModel) closeCmd() tea.Cmd {
+	mode := m.returnMode
+	return func() tea.Msg {
+		return ChangeViewModeMsg(mode)
+	}
+}
+
+func (m CopyMenuModel) copySelectedCmd() tea.Cmd {
+	if m.option == nil {
+		return m.closeCmd()
+	}
+
+	text, err := m.option.Format(m.formats[m.selected], m.value)
+	if err != nil {
+		return tea.Batch(m.closeCmd(), func() tea.Msg {
+			return NotificationMsg{
+				Message: "Copy failed: " + err.Error(),
+				Kind:    NotificationError,
+			}
+		})
+	}
+
+	return tea.Batch(m.closeCmd(), copyToClipboardCmd(m.clipboardBackends, text))
+}
+
+func (m CopyMenuModel) View() string {
+	// Account for the border on each side.
+	innerWidth := max(m.width-2, 0)
+	innerHeight := max(m.height-2, 0)
+
+	style := focusedBorderStyle.Width(innerWidth).Height(innerHeight)
+
+	title := lipgloss.PlaceHorizontal(innerWidth, lipgloss.Center, titleStyle.Render("Copy As"))
+	line := lipgloss.NewStyle().Width(innerWidth).Inherit(titleRuleStyle).Render("")
+
+	if m.option == nil {
+		return style.Render(title + "\n" + line + "\n  No option selected.")
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%v\n\n", boldStyle.Render(m.option.Name))
+
+	for i, f := range m.formats {
+		text := fmt.Sprintf("%d. %s", i+1, f.Description())
+		if i == m.selected {
+			sb.WriteString(copyMenuSelectedItemStyle.Render(text))
+		} else {
+			sb.WriteString(copyMenuItemStyle.Render(text))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n" + attrStyle.Render("Preview") + "\n\n")
+
+	preview, err := m.option.Format(m.formats[m.selected], m.value)
+	if err != nil {
+		sb.WriteString(errorTextStyle.Render(err.Error()))
+	} else {
+		sb.WriteString(copyMenuPreviewStyle.Render(preview))
+	}
+
+	// Leave room for the title and its rule.
+	body := lipgloss.NewStyle().
+		Width(innerWidth).
+		MaxHeight(max(innerHeight-2, 0)).
+		Render(sb.String())
+
+	return style.Render(title + "\n" + line + "\n" + body)
+}
@@ -21,7 +21,8 @@ func init() {
 }
 
 type HelpModel struct {
-	vp viewport.Model
+	vp     viewport.Model
+	search PagerSearchModel
 
 	width  int
 	height int
@@ -34,13 +35,22 @@ func NewHelpModel() HelpModel {
 	vp.Style = focusedBorderStyle
 
 	return HelpModel{
-		vp: vp,
+		vp:     vp,
+		search: NewPagerSearchModel(),
 	}
 }
 
 func (m HelpModel) Update(msg tea.Msg) (HelpModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		var searchCmd tea.Cmd
+		var handled bool
+		m.search, searchCmd, handled = m.search.HandleKey(msg)
+		if handled {
+			m = m.refreshSearch()
+			return m, searchCmd
+		}
+
 		switch msg.String() {
 		case "q", "esc":
 			return m, func() tea.Msg {
@@ -53,9 +63,9 @@ func (m HelpModel) Update(msg tea.Msg) (HelpModel, tea.Cmd) {
 		m.height = msg.Height - 4
 
 		m.vp.Width = m.width
-		m.vp.Height = m.height
 
-		m.vp.SetContent(m.constructHelpContent())
+		m.search = m.search.SetContent(m.constructHelpContent())
+		m = m.refreshSearch()
 
 		return m, nil
 	}
@@ -66,7 +76,36 @@ func (m HelpModel) Update(msg tea.Msg) (HelpModel, tea.Cmd) {
 	return m, cmd
 }
 
+// Re-render the help content with any search matches highlighted,
+// and scroll to the current match.
+func (m HelpModel) refreshSearch() HelpModel {
+	m.vp.Height = m.height
+	if m.search.Visible() {
+		// Leave room for the search bar.
+		m.vp.Height--
+	}
+
+	m.vp.SetContent(m.search.Render())
+	scrollToLine(&m.vp, m.search.CurrentLine())
+
+	return m
+}
+
+// Whether or not the help search bar is currently visible.
+func (m HelpModel) Searching() bool {
+	return m.search.Visible()
+}
+
+// Whether or not a search query is currently being typed.
+func (m HelpModel) SearchTyping() bool {
+	return m.search.Typing()
+}
+
 func (m HelpModel) View() string {
+	if m.search.Visible() {
+		return lipgloss.JoinVertical(lipgloss.Left, m.vp.View(), m.search.View(m.width))
+	}
+
 	return m.vp.View()
 }
 
@@ -0,0 +1,247 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+var (
+	pagerMatchStyle = lipgloss.NewStyle().
+			Background(lipgloss.ANSIColor(termenv.ANSIYellow)).
+			Foreground(lipgloss.ANSIColor(termenv.ANSIBlack))
+	pagerCurrentMatchStyle = lipgloss.NewStyle().
+				Background(lipgloss.ANSIColor(termenv.ANSIMagenta)).
+				Foreground(lipgloss.ANSIColor(termenv.ANSIBrightWhite)).
+				Bold(true)
+	pagerSearchBarStyle = lipgloss.NewStyle().Foreground(ansiBlue)
+)
+
+type pagerMatch struct {
+	line  int
+	start int
+	end   int
+}
+
+// A less-style search for content displayed in a viewport.
+//
+// Owners of this model are responsible for passing content to it,
+// rendering the highlighted content it produces into their viewport,
+// and scrolling to the current match line when it changes.
+type PagerSearchModel struct {
+	input textinput.Model
+
+	// Whether or not the query is currently being typed in
+	typing bool
+	query  string
+
+	lines []string
+	plain []string
+
+	matches []pagerMatch
+	current int
+}
+
+func NewPagerSearchModel() PagerSearchModel {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "Search..."
+
+	return PagerSearchModel{
+		input: ti,
+	}
+}
+
+// Set the content to search through. Any existing query will be
+// re-run against the new content.
+func (m PagerSearchModel) SetContent(content string) PagerSearchModel {
+	m.lines = strings.Split(content, "\n")
+	m.plain = make([]string, len(m.lines))
+	for i, l := range m.lines {
+		m.plain[i] = ansi.Strip(l)
+	}
+
+	m = m.findMatches(m.query)
+	return m
+}
+
+// Whether or not the search bar should be displayed.
+func (m PagerSearchModel) Visible() bool {
+	return m.typing || m.query != ""
+}
+
+// Whether or not the query is currently being typed in.
+func (m PagerSearchModel) Typing() bool {
+	return m.typing
+}
+
+// Line number of the current match, or -1 if there are no matches.
+func (m PagerSearchModel) CurrentLine() int {
+	if len(m.matches) == 0 {
+		return -1
+	}
+	return m.matches[m.current].line
+}
+
+// Handle a key press. Returns whether or not the key was handled;
+// if not, it should be processed by the owner of this model. If it
+// was, the owner should re-render its content and scroll to the
+// current match.
+func (m PagerSearchModel) HandleKey(msg tea.KeyMsg) (PagerSearchModel, tea.Cmd, bool) {
+	if m.typing {
+		switch msg.String() {
+		case "enter":
+			m.typing = false
+			m.input.Blur()
+			m = m.findMatches(m.input.Value())
+			return m, nil, true
+		case "esc":
+			m = m.Clear()
+			return m, nil, true
+		}
+
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+
+		// Search incrementally as the query is typed.
+		m = m.findMatches(m.input.Value())
+
+		return m, cmd, true
+	}
+
+	switch msg.String() {
+	case "/":
+		m.typing = true
+		m.input.SetValue("")
+		return m, m.input.Focus(), true
+	case "n":
+		if len(m.matches) > 0 {
+			m.current = (m.current + 1) % len(m.matches)
+			return m, nil, true
+		}
+	case "N":
+		if len(m.matches) > 0 {
+			m.current = (m.current - 1 + len(m.matches)) % len(m.matches)
+			return m, nil, true
+		}
+	case "esc":
+		if m.query != "" {
+			m = m.Clear()
+			return m, nil, true
+		}
+	}
+
+	return m, nil, false
+}
+
+func (m PagerSearchModel) Clear() PagerSearchModel {
+	m.typing = false
+	m.input.Blur()
+	m.input.SetValue("")
+	m = m.findMatches("")
+	return m
+}
+
+func (m PagerSearchModel) findMatches(query string) PagerSearchModel {
+	m.query = query
+	m.matches = nil
+	m.current = 0
+
+	if query == "" {
+		return m
+	}
+
+	// Use smart case, like most pagers: only match case-sensitively
+	// if the query contains an uppercase character.
+	pattern := regexp.QuoteMeta(query)
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		pattern = "(?i)" + pattern
+	}
+	expr := regexp.MustCompile(pattern)
+
+	for i, line := range m.plain {
+		for _, loc := range expr.FindAllStringIndex(line, -1) {
+			m.matches = append(m.matches, pagerMatch{line: i, start: loc[0], end: loc[1]})
+		}
+	}
+
+	return m
+}
+
+// Render the content with all matches highlighted.
+//
+// Lines containing matches lose their original styling, since
+// highlighting inside of existing escape sequences is unreliable.
+func (m PagerSearchModel) Render() string {
+	if len(m.matches) == 0 {
+		return strings.Join(m.lines, "\n")
+	}
+
+	lines := make([]string, len(m.lines))
+	copy(lines, m.lines)
+
+	for i := 0; i < len(m.matches); {
+		lineIdx := m.matches[i].line
+		plain := m.plain[lineIdx]
+
+		var sb strings.Builder
+		last := 0
+
+		for ; i < len(m.matches) && m.matches[i].line == lineIdx; i++ {
+			match := m.matches[i]
+
+			style := pagerMatchStyle
+			if i == m.current {
+				style = pagerCurrentMatchStyle
+			}
+
+			sb.WriteString(plain[last:match.start])
+			sb.WriteString(style.Render(plain[match.start:match.end]))
+			last = match.end
+		}
+		sb.WriteString(plain[last:])
+
+		lines[lineIdx] = sb.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m PagerSearchModel) View(width int) string {
+	var text string
+
+	switch {
+	case m.typing:
+		m.input.Width = max(width-2, 0)
+		text = m.input.View()
+	case len(m.matches) == 0:
+		text = errorTextStyle.Render(fmt.Sprintf("/%s: pattern not found", m.query))
+	default:
+		text = pagerSearchBarStyle.Render(fmt.Sprintf("/%s [%d/%d] (n/N to navigate, Esc to clear)", m.query, m.current+1, len(m.matches)))
+	}
+
+	return lipgloss.NewStyle().MaxWidth(width).Render(text)
+}
+
+// Scroll a viewport so that the given line is visible, centering it
+// if it is not already in view. Negative lines are ignored.
+func scrollToLine(vp *viewport.Model, line int) {
+	if line < 0 {
+		return
+	}
+
+	visible := vp.Height - vp.Style.GetVerticalFrameSize()
+	if line >= vp.YOffset && line < vp.YOffset+visible {
+		return
+	}
+
+	vp.SetYOffset(line - visible/2)
+}
@@ -11,7 +11,10 @@ import (
 )
 
 type PreviewModel struct {
-	vp viewport.Model
+	vp     viewport.Model
+	search PagerSearchModel
+
+	height int
 
 	option       *option.NixosOption
 	focused      bool
@@ -21,14 +24,17 @@ type PreviewModel struct {
 func NewPreviewModel() PreviewModel {
 	vp := viewport.New(0, 0)
 	vp.SetHorizontalStep(1)
+	vp.Style = inactiveBorderStyle
 
 	return PreviewModel{
-		vp: vp,
+		vp:     vp,
+		search: NewPagerSearchModel(),
 	}
 }
 
 func (m PreviewModel) SetHeight(height int) PreviewModel {
-	m.vp.Height = height
+	m.height = height
+	m = m.refreshSearch()
 	return m
 }
 
@@ -39,6 +45,13 @@ func (m PreviewModel) SetWidth(width int) PreviewModel {
 
 func (m PreviewModel) SetFocused(focus bool) PreviewModel {
 	m.focused = focus
+
+	if focus {
+		m.vp.Style = focusedBorderStyle
+	} else {
+		m.vp.Style = inactiveBorderStyle
+	}
+
 	return m
 }
 
@@ -52,13 +65,23 @@ var titleColor = color.New(color.Bold)
 func (m PreviewModel) Update(msg tea.Msg) (PreviewModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.focused {
+			var searchCmd tea.Cmd
+			var handled bool
+			m.search, searchCmd, handled = m.search.HandleKey(msg)
+			if handled {
+				m = m.refreshSearch()
+				return m, searchCmd
+			}
+		}
+
 		switch msg.String() {
 		case "enter":
 			if m.option == nil {
 				break
 			}
 			changeModeCmd := func() tea.Msg {
-				return EvalValueStartMsg{Option: m.option.Name}
+				return EvalValueStartMsg{Option: m.option.Name, Location: m.option.Location}
 			}
 			return m, changeModeCmd
 		}
@@ -82,8 +105,7 @@ func (m PreviewModel) Update(msg tea.Msg) (PreviewModel, tea.Cmd) {
 		return m, cmd
 	}
 
-	m.vp.SetContent(m.renderOptionView())
-	m.vp.GotoTop()
+	m = m.ForceContentUpdate()
 
 	m.lastRendered = o
 
@@ -91,12 +113,38 @@ func (m PreviewModel) Update(msg tea.Msg) (PreviewModel, tea.Cmd) {
 }
 
 func (m PreviewModel) ForceContentUpdate() PreviewModel {
-	m.vp.SetContent(m.renderOptionView())
+	m.search = m.search.SetContent(m.renderOptionView())
 	m.vp.GotoTop()
+	m = m.refreshSearch()
 
 	return m
 }
 
+// Re-render the preview content with any search matches highlighted,
+// and scroll to the current match.
+func (m PreviewModel) refreshSearch() PreviewModel {
+	m.vp.Height = m.height
+	if m.search.Visible() {
+		// Leave room for the search bar.
+		m.vp.Height--
+	}
+
+	m.vp.SetContent(m.search.Render())
+	scrollToLine(&m.vp, m.search.CurrentLine())
+
+	return m
+}
+
+// Whether or not the preview search bar is currently visible.
+func (m PreviewModel) Searching() bool {
+	return m.search.Visible()
+}
+
+// Whether or not a search query is currently being typed.
+func (m PreviewModel) SearchTyping() bool {
+	return m.search.Typing()
+}
+
 func (m PreviewModel) renderOptionView() string {
 	o := m.option
 
@@ -117,10 +165,8 @@ func (m PreviewModel) renderOptionView() string {
 }
 
 func (m PreviewModel) View() string {
-	if m.focused {
-		m.vp.Style = focusedBorderStyle
-	} else {
-		m.vp.Style = inactiveBorderStyle
+	if m.search.Visible() {
+		return lipgloss.JoinVertical(lipgloss.Left, m.vp.View(), m.search.View(m.vp.Width))
 	}
 
 	return m.vp.View()
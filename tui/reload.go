@@ -0,0 +1,207 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+	"snare.dev/optnix/option"
+)
+
+// Reload the configuration, returning the new list of scopes. The
+// scope with the given name must be in the list if it still exists,
+// even if it was created by giving values to a scope's parameters.
+type ReloadFunc func(selectedScope string) (ReloadResult, error)
+
+type ReloadResult struct {
+	Scopes []option.Scope
+	// Files that should trigger a reload when they change in watch
+	// mode, such as configuration files and options list files.
+	WatchFiles []string
+}
+
+type ReloadFinishedMsg struct {
+	Result  ReloadResult
+	Scope   option.Scope
+	Options option.NixosOptionSource
+	// What caused the reload, if it was not requested by the user
+	Reason string
+	Err    error
+}
+
+// Sent by the file watcher when a watched file changes.
+type FileChangedMsg struct {
+	Path string
+}
+
+type reloadDebounceMsg struct {
+	ID   int
+	Path string
+}
+
+// Editors and builds tend to change files several times in a row,
+// so wait for changes to settle before reloading.
+const watchDebounceTime = 250 * time.Millisecond
+
+func (m Model) debounceFileChange(msg FileChangedMsg) (Model, tea.Cmd) {
+	m.watchDebounceID++
+	id := m.watchDebounceID
+
+	return m, tea.Tick(watchDebounceTime, func(time.Time) tea.Msg {
+		return reloadDebounceMsg{ID: id, Path: msg.Path}
+	})
+}
+
+func (m Model) startReload(reason string) (Model, tea.Cmd) {
+	if m.reloading {
+		m.reloadPending = true
+		m.pendingReloadReason = reason
+		return m, nil
+	}
+	m.reloading = true
+
+	reload := m.reload
+	selected := m.selectScope.SelectedScope()
+	scopes := m.selectScope.Scopes()
+
+	notify := func() tea.Msg {
+		return NotificationMsg{Message: fmt.Sprintf("Reloading scope %v...", selected.Name)}
+	}
+
+	reloadCmd := func() tea.Msg {
+		result := ReloadResult{Scopes: scopes}
+
+		if reload != nil {
+			var err error
+			result, err = reload(selected.Name)
+			if err != nil {
+				return ReloadFinishedMsg{Reason: reason, Err: err}
+			}
+		}
+
+		i := slices.IndexFunc(result.Scopes, func(s option.Scope) bool {
+			return s.Name == selected.Name
+		})
+		if i == -1 {
+			return ReloadFinishedMsg{
+				Reason: reason,
+				Err:    fmt.Errorf("scope '%v' no longer exists", selected.Name),
+			}
+		}
+
+		scope := result.Scopes[i]
+		options, err := scope.Loader()
+
+		return ReloadFinishedMsg{
+			Result:  result,
+			Scope:   scope,
+			Options: options,
+			Reason:  reason,
+			Err:     err,
+		}
+	}
+
+	return m, tea.Batch(notify, reloadCmd)
+}
+
+func (m Model) finishReload(msg ReloadFinishedMsg) (Model, tea.Cmd) {
+	m.reloading = false
+
+	m, cmd := m.applyReload(msg)
+
+	if m.reloadPending {
+		reason := m.pendingReloadReason
+		m.reloadPending = false
+		m.pendingReloadReason = ""
+
+		var reloadCmd tea.Cmd
+		m, reloadCmd = m.startReload(reason)
+		cmd = tea.Batch(cmd, reloadCmd)
+	}
+
+	return m, cmd
+}
+
+// Replace the scopes and options with reloaded ones, while keeping
+// the current search query and selected option.
+func (m Model) applyReload(msg ReloadFinishedMsg) (Model, tea.Cmd) {
+	if msg.Err != nil {
+		// Errors can contain hints on later lines, which do not
+		// fit in the status bar.
+		errMsg, _, _ := strings.Cut(msg.Err.Error(), "\n")
+
+		return m, func() tea.Msg {
+			return NotificationMsg{
+				Message: "Reload failed: " + errMsg,
+				Kind:    NotificationError,
+			}
+		}
+	}
+
+	// The scope could have been switched while reloading, which
+	// makes these options outdated.
+	if msg.Scope.Name != m.selectScope.SelectedScope().Name {
+		return m, nil
+	}
+
+	var cmds []tea.Cmd
+
+	if m.watcher != nil {
+		m.watcher.Watch(msg.Result.WatchFiles)
+	}
+
+	var selectCmd tea.Cmd
+	m.selectScope, selectCmd = m.selectScope.SetScopes(msg.Result.Scopes)
+	cmds = append(cmds, selectCmd)
+	m.enableScopeSwitching = canSwitchScopes(msg.Result.Scopes)
+
+	m.options = msg.Options
+	m.eval = m.eval.SetEvaluator(msg.Scope.Evaluator).
+		SetEvaluatorOutput(msg.Scope.EvaluatorOutput).
+		SetDefinitions(msg.Scope.Definitions)
+
+	if m.mode == ViewModeEvalValue {
+		var evalCmd tea.Cmd
+		m.eval, evalCmd = m.eval.Reevaluate()
+		cmds = append(cmds, evalCmd)
+	} else {
+		m.eval = m.eval.ClearValue()
+	}
+
+	var selectedName string
+	if opt := m.results.GetSelectedOption(); opt != nil {
+		selectedName = opt.Name
+	}
+
+	m.results = m.results.SetOptions(msg.Options)
+	m.search = m.search.SetTotalCount(len(msg.Options))
+
+	m = m.runSearch(m.search.Value(), m.search.Mode())
+	m.search = m.search.SetResultCount(len(m.filtered))
+
+	if i := slices.IndexFunc(m.filtered, func(match fuzzy.Match) bool {
+		return msg.Options[match.Index].Name == selectedName
+	}); i != -1 {
+		m.results = m.results.SetSelectedIndex(i)
+	}
+
+	m.preview = m.preview.SetOption(m.results.GetSelectedOption())
+
+	message := fmt.Sprintf("Reloaded scope %v (%d options)", msg.Scope.Name, len(msg.Options))
+	if msg.Reason != "" {
+		message += " after " + msg.Reason
+	}
+	cmds = append(cmds, func() tea.Msg {
+		return NotificationMsg{Message: message}
+	})
+
+	return m, tea.Batch(cmds...)
+}
+
+func fileChangedReason(path string) string {
+	return fmt.Sprintf("%v changed", filepath.Base(path))
+}
@@ -48,6 +48,11 @@ func NewResultListModel(options option.NixosOptionSource, scopeName string) Resu
 	}
 }
 
+func (m ResultListModel) SetOptions(options option.NixosOptionSource) ResultListModel {
+	m.options = options
+	return m
+}
+
 func (m ResultListModel) SetResultList(matches []fuzzy.Match) ResultListModel {
 	m.filtered = matches
 	return m
@@ -149,7 +154,7 @@ func (m ResultListModel) Update(msg tea.Msg) (ResultListModel, tea.Cmd) {
 
 			changeModeCmd := func() tea.Msg {
 				o := m.options[m.filtered[m.selected].Index]
-				return EvalValueStartMsg{Option: o.Name}
+				return EvalValueStartMsg{Option: o.Name, Location: o.Location}
 			}
 
 			return m, changeModeCmd
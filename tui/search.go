@@ -147,6 +147,15 @@ func (m SearchBarModel) SetValue(input string) SearchBarModel {
 	return m
 }
 
+func (m SearchBarModel) SetTotalCount(count int) SearchBarModel {
+	m.totalCount = count
+	return m
+}
+
+func (m SearchBarModel) Mode() SearchMode {
+	return m.searchMode
+}
+
 func (m SearchBarModel) Value() string {
 	return m.input.Value()
 }
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"cmp"
 	"fmt"
 	"io"
 	"slices"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -31,23 +33,28 @@ var (
 	attrStyle         = lipgloss.NewStyle().Foreground(ansiCyan)
 	boldStyle         = lipgloss.NewStyle().Bold(true)
 	italicStyle       = lipgloss.NewStyle().Italic(true)
+	groupHeadingStyle = lipgloss.NewStyle().MarginLeft(2).Bold(true).Foreground(ansiMagenta)
 )
 
 type LoadScopeStartMsg option.Scope
 
 type LoadScopeFinishedMsg struct {
-	Name      string
-	Options   option.NixosOptionSource
-	Evaluator option.EvaluatorFunc
-	Err       error
+	Name            string
+	Options         option.NixosOptionSource
+	Evaluator       option.EvaluatorFunc
+	EvaluatorOutput option.EvaluatorOutput
+	Definitions     option.DefinitionsFunc
+	Err             error
 }
 
 type ChangeScopeMsg struct {
-	Name       string
-	Options    option.NixosOptionSource
-	Evaluator  option.EvaluatorFunc
-	KeepSearch bool
-	Err        error
+	Name            string
+	Options         option.NixosOptionSource
+	Evaluator       option.EvaluatorFunc
+	EvaluatorOutput option.EvaluatorOutput
+	Definitions     option.DefinitionsFunc
+	KeepSearch      bool
+	Err             error
 }
 
 type scopeItem struct {
@@ -60,11 +67,25 @@ func (i scopeItem) FilterValue() string {
 	return fmt.Sprintf("%v %v", scope.Name, scope.Description)
 }
 
-type scopeItemDelegate struct{}
+type scopeItemDelegate struct {
+	// Whether or not to show group headings, which take the place
+	// of the spacing between items.
+	grouped bool
+}
 
-func (d scopeItemDelegate) Height() int { return 2 }
+func (d scopeItemDelegate) Height() int {
+	if d.grouped {
+		return 3
+	}
+	return 2
+}
 
-func (d scopeItemDelegate) Spacing() int { return 1 }
+func (d scopeItemDelegate) Spacing() int {
+	if d.grouped {
+		return 0
+	}
+	return 1
+}
 
 func (d scopeItemDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
 
@@ -78,6 +99,15 @@ func (d scopeItemDelegate) Render(w io.Writer, m list.Model, index int, listItem
 
 	s := i.Scope
 
+	var heading string
+	if d.grouped {
+		visible := m.VisibleItems()
+		if index == 0 || visible[index-1].(scopeItem).Scope.Group != s.Group {
+			heading = groupHeadingStyle.Render(cmp.Or(s.Group, "Other"))
+		}
+		heading += "\n"
+	}
+
 	var str string
 	if !i.Selected {
 		str = boldStyle.Render(s.Name)
@@ -85,6 +115,10 @@ func (d scopeItemDelegate) Render(w io.Writer, m list.Model, index int, listItem
 		str = boldStyle.Render(fmt.Sprintf("%v%v", s.Name, selectedText))
 	}
 
+	if s.NeedsParameters() {
+		str += italicStyle.Render(fmt.Sprintf(" (parameters: %v)", strings.Join(s.Parameters, ", ")))
+	}
+
 	str += fmt.Sprintf("\n%s :: %s", attrStyle.Render("Description"), s.Description)
 
 	fn := itemStyle.Render
@@ -99,7 +133,7 @@ func (d scopeItemDelegate) Render(w io.Writer, m list.Model, index int, listItem
 		}
 	}
 
-	_, _ = fmt.Fprint(w, fn(str))
+	_, _ = fmt.Fprint(w, heading+fn(str))
 }
 
 type SelectScopeModel struct {
@@ -113,15 +147,18 @@ type SelectScopeModel struct {
 	scopes        []option.Scope
 	selectedScope string
 
+	// Scope that values for parameters are being entered for
+	paramScope *option.Scope
+	paramInput textinput.Model
+	paramErr   error
+
 	loading bool
 	err     error
 }
 
+// Create a scope picker. Scopes are listed in the order they are
+// given in.
 func NewSelectScopeModel(scopes []option.Scope, selectedScope string) SelectScopeModel {
-	slices.SortFunc(scopes, func(a, b option.Scope) int {
-		return strings.Compare(a.Name, b.Name)
-	})
-
 	items := make([]list.Item, len(scopes))
 	for i, s := range scopes {
 		selected := s.Name == selectedScope
@@ -132,7 +169,11 @@ func NewSelectScopeModel(scopes []option.Scope, selectedScope string) SelectScop
 		}
 	}
 
-	l := list.New(items, scopeItemDelegate{}, 0, 0)
+	grouped := slices.ContainsFunc(scopes, func(s option.Scope) bool {
+		return s.Group != ""
+	})
+
+	l := list.New(items, scopeItemDelegate{grouped: grouped}, 0, 0)
 
 	l.Title = "Available Scopes"
 
@@ -155,30 +196,143 @@ func NewSelectScopeModel(scopes []option.Scope, selectedScope string) SelectScop
 	sp.Spinner = spinner.Line
 	sp.Style = spinnerStyle
 
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.PromptStyle = lipgloss.NewStyle().Foreground(ansiBlue).Bold(true)
+
 	return SelectScopeModel{
-		list:    l,
-		vp:      vp,
-		spinner: sp,
+		list:       l,
+		vp:         vp,
+		spinner:    sp,
+		paramInput: ti,
 
 		scopes:        scopes,
 		selectedScope: selectedScope,
 	}
 }
 
-func (m SelectScopeModel) NextScope() option.Scope {
-	for i, s := range m.scopes {
+// Replace the list of scopes, such as after configuration has been
+// reloaded. The selected scope stays the same.
+func (m SelectScopeModel) SetScopes(scopes []option.Scope) (SelectScopeModel, tea.Cmd) {
+	m.scopes = scopes
+
+	grouped := slices.ContainsFunc(scopes, func(s option.Scope) bool {
+		return s.Group != ""
+	})
+	m.list.SetDelegate(scopeItemDelegate{grouped: grouped})
+
+	return m, m.list.SetItems(m.scopeItems())
+}
+
+func (m SelectScopeModel) Scopes() []option.Scope {
+	return m.scopes
+}
+
+// Retrieve the currently selected scope.
+func (m SelectScopeModel) SelectedScope() option.Scope {
+	for _, s := range m.scopes {
 		if s.Name == m.selectedScope {
-			return m.scopes[(i+1)%len(m.scopes)]
+			return s
 		}
 	}
 	return m.scopes[0]
 }
 
+// Retrieve the scope after the currently selected one, skipping
+// scopes that need values for their parameters.
+func (m SelectScopeModel) NextScope() option.Scope {
+	start := slices.IndexFunc(m.scopes, func(s option.Scope) bool {
+		return s.Name == m.selectedScope
+	})
+
+	for i := 1; i <= len(m.scopes); i++ {
+		next := m.scopes[(start+i)%len(m.scopes)]
+		if !next.NeedsParameters() {
+			return next
+		}
+	}
+
+	return m.SelectedScope()
+}
+
+// Whether or not values for a scope's parameters are being entered.
+func (m SelectScopeModel) Prompting() bool {
+	return m.paramScope != nil
+}
+
+func (m SelectScopeModel) startParamPrompt(scope option.Scope) (SelectScopeModel, tea.Cmd) {
+	m.paramScope = &scope
+	m.paramErr = nil
+
+	m.paramInput.Reset()
+	m.paramInput.Placeholder = strings.Join(scope.Parameters, ",")
+	cmd := m.paramInput.Focus()
+
+	return m, cmd
+}
+
+func (m SelectScopeModel) updateParamPrompt(msg tea.KeyMsg) (SelectScopeModel, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.paramScope = nil
+		m.paramInput.Blur()
+		return m, nil
+
+	case "enter":
+		bound, err := m.paramScope.Bind(strings.TrimSpace(m.paramInput.Value()))
+		if err != nil {
+			m.paramErr = err
+			return m, nil
+		}
+
+		m.paramScope = nil
+		m.paramInput.Blur()
+
+		return m, func() tea.Msg {
+			return LoadScopeStartMsg(bound)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.paramInput, cmd = m.paramInput.Update(msg)
+	m.paramErr = nil
+
+	return m, cmd
+}
+
+// Insert a scope after the last scope in its group, so that groups
+// stay together.
+func (m SelectScopeModel) insertScope(scope option.Scope) []option.Scope {
+	i := len(m.scopes)
+	for j, s := range m.scopes {
+		if s.Group == scope.Group {
+			i = j + 1
+		}
+	}
+
+	return slices.Insert(slices.Clone(m.scopes), i, scope)
+}
+
+func (m SelectScopeModel) scopeItems() []list.Item {
+	items := make([]list.Item, len(m.scopes))
+	for i, s := range m.scopes {
+		items[i] = scopeItem{
+			Scope:    s,
+			Selected: s.Name == m.selectedScope,
+		}
+	}
+	return items
+}
+
 func (m SelectScopeModel) Update(msg tea.Msg) (SelectScopeModel, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.paramScope != nil {
+			return m.updateParamPrompt(msg)
+		}
+
 		if m.list.FilterState() == list.Filtering {
 			break
 		}
@@ -200,6 +354,10 @@ func (m SelectScopeModel) Update(msg tea.Msg) (SelectScopeModel, tea.Cmd) {
 				break
 			}
 
+			if item.Scope.NeedsParameters() {
+				return m.startParamPrompt(item.Scope)
+			}
+
 			return m, func() tea.Msg {
 				return LoadScopeStartMsg(item.Scope)
 			}
@@ -208,14 +366,7 @@ func (m SelectScopeModel) Update(msg tea.Msg) (SelectScopeModel, tea.Cmd) {
 	case ChangeScopeMsg:
 		m.selectedScope = msg.Name
 
-		items := make([]list.Item, len(m.scopes))
-		for i, s := range m.scopes {
-			items[i] = scopeItem{
-				Scope:    s,
-				Selected: s.Name == m.selectedScope,
-			}
-		}
-		cmds = append(cmds, m.list.SetItems(items))
+		cmds = append(cmds, m.list.SetItems(m.scopeItems()))
 
 		return m, tea.Batch(cmds...)
 
@@ -236,23 +387,23 @@ func (m SelectScopeModel) Update(msg tea.Msg) (SelectScopeModel, tea.Cmd) {
 		m.err = nil
 		m.selectedScope = msg.Name
 
-		items := make([]list.Item, len(m.scopes))
-		for i, s := range m.scopes {
-			items[i] = scopeItem{
-				Scope:    s,
-				Selected: s.Name == m.selectedScope,
-			}
+		// Scopes with parameter values are added to the list, so that
+		// they can be switched back to later.
+		if !slices.ContainsFunc(m.scopes, func(s option.Scope) bool { return s.Name == msg.Name }) {
+			m.scopes = m.insertScope(option.Scope(msg))
 		}
 
 		cmds = append(cmds, m.spinner.Tick)
-		cmds = append(cmds, m.list.SetItems(items))
+		cmds = append(cmds, m.list.SetItems(m.scopeItems()))
 		cmds = append(cmds, func() tea.Msg {
 			loaded, err := msg.Loader()
 			return LoadScopeFinishedMsg{
-				Name:      msg.Name,
-				Options:   loaded,
-				Err:       err,
-				Evaluator: msg.Evaluator,
+				Name:            msg.Name,
+				Options:         loaded,
+				Err:             err,
+				Evaluator:       msg.Evaluator,
+				EvaluatorOutput: msg.EvaluatorOutput,
+				Definitions:     msg.Definitions,
 			}
 		})
 
@@ -270,9 +421,11 @@ func (m SelectScopeModel) Update(msg tea.Msg) (SelectScopeModel, tea.Cmd) {
 		if m.err == nil {
 			return m, func() tea.Msg {
 				return ChangeScopeMsg{
-					Name:      msg.Name,
-					Options:   msg.Options,
-					Evaluator: msg.Evaluator,
+					Name:            msg.Name,
+					Options:         msg.Options,
+					Evaluator:       msg.Evaluator,
+					EvaluatorOutput: msg.EvaluatorOutput,
+					Definitions:     msg.Definitions,
 				}
 			}
 		}
@@ -284,6 +437,13 @@ func (m SelectScopeModel) Update(msg tea.Msg) (SelectScopeModel, tea.Cmd) {
 		m.vp.SetContent(errorTextStyle.Render(fmt.Sprintf("error loading scope: %v\n\nPress any key to go back.\n", m.err)))
 	}
 
+	// Keep the cursor of the parameter prompt blinking.
+	if m.paramScope != nil {
+		var inputCmd tea.Cmd
+		m.paramInput, inputCmd = m.paramInput.Update(msg)
+		cmds = append(cmds, inputCmd)
+	}
+
 	var vpCmd tea.Cmd
 	m.vp, vpCmd = m.vp.Update(msg)
 	cmds = append(cmds, vpCmd)
@@ -300,5 +460,27 @@ func (m SelectScopeModel) View() string {
 		return m.vp.View()
 	}
 
+	if m.paramScope != nil {
+		return m.paramPromptView()
+	}
+
 	return "\n\n" + m.list.View()
 }
+
+func (m SelectScopeModel) paramPromptView() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%v\n\n", boldStyle.Render(fmt.Sprintf("Parameters for scope %v", m.paramScope.Name)))
+	fmt.Fprintf(&sb, "Enter values for %v, separated by commas\n", attrStyle.Render(strings.Join(m.paramScope.Parameters, ", ")))
+	sb.WriteString("either in order (i.e. a,b) or by name (i.e. name=a).\n\n")
+	sb.WriteString(m.paramInput.View())
+
+	if m.paramErr != nil {
+		fmt.Fprintf(&sb, "\n\n%v", errorTextStyle.Render(m.paramErr.Error()))
+	}
+
+	sb.WriteString("\n\n")
+	sb.WriteString(italicStyle.Render("Press enter to load the scope, or esc to go back."))
+
+	return "\n\n" + lipgloss.NewStyle().PaddingLeft(4).Render(sb.String())
+}
@@ -2,15 +2,18 @@ package tui
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"slices"
+	"time"
 	"unicode"
 
-	"github.com/atotto/clipboard"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
 	"github.com/muesli/termenv"
 	"github.com/sahilm/fuzzy"
+	"snare.dev/optnix/internal/clipboard"
 	cmdUtils "snare.dev/optnix/internal/cmd/utils"
 	"snare.dev/optnix/internal/utils"
 	"snare.dev/optnix/option"
@@ -46,6 +49,25 @@ type Model struct {
 	filtered []fuzzy.Match
 	minScore int64
 
+	clipboardBackends []clipboard.Backend
+	// Escape sequence for copying through the terminal, which is
+	// written along with the view until it has been drawn
+	clipboardSequence   string
+	clipboardSequenceID int
+
+	// Reloads configuration with ctrl+r; if this is not set, only
+	// the options of the current scope are reloaded.
+	reload    ReloadFunc
+	reloading bool
+	// Set when a reload was requested while another one was running,
+	// so that changes made in the meantime are not missed.
+	reloadPending       bool
+	pendingReloadReason string
+
+	// Only set in watch mode
+	watcher         *fileWatcher
+	watchDebounceID int
+
 	width  int
 	height int
 
@@ -57,6 +79,8 @@ type Model struct {
 	selectScope SelectScopeModel
 	eval        EvalValueModel
 	help        HelpModel
+	copyMenu    CopyMenuModel
+	compare     CompareModel
 }
 
 type ViewMode int
@@ -66,6 +90,8 @@ const (
 	ViewModeSelectScope
 	ViewModeEvalValue
 	ViewModeHelp
+	ViewModeCopyMenu
+	ViewModeCompare
 )
 
 type ChangeViewModeMsg ViewMode
@@ -86,16 +112,55 @@ type ClearNotificationMsg struct {
 	ID int
 }
 
-func copyToClipboardCmd(value string) tea.Cmd {
+func copyToClipboardCmd(backends []clipboard.Backend, value string) tea.Cmd {
 	return func() tea.Msg {
-		if err := clipboard.WriteAll(value); err != nil {
+		result, err := clipboard.Copy(backends, value)
+		if err != nil {
 			return NotificationMsg{
 				Message: "Copy failed: " + err.Error(),
 				Kind:    NotificationError,
 			}
 		}
-		return NotificationMsg{Message: "Copied to clipboard!"}
+
+		if result.Sequence != "" {
+			return terminalClipboardMsg(result)
+		}
+
+		return NotificationMsg{
+			Message: fmt.Sprintf("Copied to clipboard using %v!", result.Backend.DisplayName()),
+		}
+	}
+}
+
+// Sent when copying needs an escape sequence to be written to the
+// terminal, which is done through the rendered view so that it does
+// not get mixed up with a frame that is being drawn.
+type terminalClipboardMsg clipboard.CopyResult
+
+type clearTerminalClipboardMsg struct {
+	ID int
+}
+
+// How long to keep a clipboard sequence in the view, which must be
+// long enough for at least one frame to be rendered with it.
+const terminalClipboardTime = 250 * time.Millisecond
+
+func (m Model) writeTerminalClipboard(msg terminalClipboardMsg) (Model, tea.Cmd) {
+	m.clipboardSequenceID++
+	id := m.clipboardSequenceID
+	m.clipboardSequence = msg.Sequence
+
+	notify := func() tea.Msg {
+		return NotificationMsg{
+			Message: fmt.Sprintf("Sent to the terminal using %v; the copy cannot be confirmed", msg.Backend.DisplayName()),
+		}
 	}
+
+	clearCmd := tea.Tick(terminalClipboardTime, func(time.Time) tea.Msg {
+		return clearTerminalClipboardMsg{ID: id}
+	})
+
+	return m, tea.Batch(notify, clearCmd)
 }
 
 type FocusArea int
@@ -136,17 +201,23 @@ func NewModel(
 	results := NewResultListModel(options, scope.Name).
 		SetFocused(true)
 	selectScope := NewSelectScopeModel(scopes, scope.Name)
-	eval := NewEvalValueModel(scope.Evaluator)
+	eval := NewEvalValueModel(scope.Evaluator).
+		SetEvaluatorOutput(scope.EvaluatorOutput).
+		SetDefinitions(scope.Definitions).
+		SetClipboardBackends(clipboard.DefaultBackends)
 	help := NewHelpModel()
+	copyMenu := NewCopyMenuModel()
+	compare := NewCompareModel()
 
 	return &Model{
 		mode:  ViewModeSearch,
 		focus: FocusAreaResults,
 
 		options:              options,
-		enableScopeSwitching: len(scopes) > 1,
+		enableScopeSwitching: canSwitchScopes(scopes),
 
-		minScore: minScore,
+		minScore:          minScore,
+		clipboardBackends: clipboard.DefaultBackends,
 
 		results:     results,
 		preview:     preview,
@@ -154,10 +225,20 @@ func NewModel(
 		selectScope: selectScope,
 		eval:        eval,
 		help:        help,
+		copyMenu:    copyMenu,
+		compare:     compare,
 		statusBar:   NewStatusBarModel(),
 	}, nil
 }
 
+// Scopes with parameters can be loaded with different values, so
+// there is something to switch to even with only one scope.
+func canSwitchScopes(scopes []option.Scope) bool {
+	return len(scopes) > 1 || slices.ContainsFunc(scopes, func(s option.Scope) bool {
+		return s.NeedsParameters()
+	})
+}
+
 func (m Model) Init() tea.Cmd {
 	if m.search.Value() != "" {
 		return func() tea.Msg {
@@ -174,13 +255,35 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		typing, searching := m.paneSearchState()
+
 		switch msg.String() {
 		case "ctrl+c":
 			return m, tea.Quit
 		case "esc":
-			if m.mode != ViewModeEvalValue {
+			if m.mode != ViewModeEvalValue && m.mode != ViewModeCopyMenu && m.mode != ViewModeCompare && !searching {
 				return m, tea.Quit
 			}
+		case "ctrl+x":
+			if !typing && (m.mode == ViewModeSearch || m.mode == ViewModeEvalValue) {
+				return m.openCopyMenu()
+			}
+		case "ctrl+t":
+			if !typing && m.enableScopeSwitching && (m.mode == ViewModeSearch || m.mode == ViewModeEvalValue) {
+				return m.openCompare()
+			}
+		case "ctrl+r":
+			if !typing && (m.mode == ViewModeSearch || m.mode == ViewModeEvalValue) {
+				return m.startReload("")
+			}
+		}
+
+		// Keys should go straight to the preview window while a search
+		// query is being typed, instead of triggering any keybinds.
+		if typing && m.mode == ViewModeSearch {
+			var previewCmd tea.Cmd
+			m.preview, previewCmd = m.preview.Update(msg)
+			return m, previewCmd
 		}
 	case tea.WindowSizeMsg:
 		m = m.updateWindowSize(msg.Width, msg.Height)
@@ -193,9 +296,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.eval, _ = m.eval.Update(overlayMsg)
 		m.help, _ = m.help.Update(overlayMsg)
 		m.selectScope, _ = m.selectScope.Update(overlayMsg)
+		m.copyMenu, _ = m.copyMenu.Update(overlayMsg)
+		m.compare, _ = m.compare.Update(overlayMsg)
 
 		return m, nil
 
+	case FileChangedMsg:
+		return m.debounceFileChange(msg)
+
+	case reloadDebounceMsg:
+		if msg.ID != m.watchDebounceID {
+			return m, nil
+		}
+		return m.startReload(fileChangedReason(msg.Path))
+
+	case ReloadFinishedMsg:
+		return m.finishReload(msg)
+
+	case terminalClipboardMsg:
+		return m.writeTerminalClipboard(msg)
+
+	case clearTerminalClipboardMsg:
+		if msg.ID == m.clipboardSequenceID {
+			m.clipboardSequence = ""
+		}
+		return m, nil
+
 	case NotificationMsg, ClearNotificationMsg:
 		var cmd tea.Cmd
 		m.statusBar, cmd = m.statusBar.Update(msg)
@@ -215,7 +341,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.mode = ViewModeSearch
 		m.options = msg.Options
-		m.eval = m.eval.SetEvaluator(msg.Evaluator)
+		m.eval = m.eval.SetEvaluator(msg.Evaluator).
+			SetEvaluatorOutput(msg.EvaluatorOutput).
+			SetDefinitions(msg.Definitions)
 		m.selectScope, _ = m.selectScope.Update(msg)
 	}
 
@@ -234,11 +362,68 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var helpCmd tea.Cmd
 		m.help, helpCmd = m.help.Update(msg)
 		return m, helpCmd
+	case ViewModeCopyMenu:
+		var copyMenuCmd tea.Cmd
+		m.copyMenu, copyMenuCmd = m.copyMenu.Update(msg)
+		return m, copyMenuCmd
+	case ViewModeCompare:
+		var compareCmd tea.Cmd
+		m.compare, compareCmd = m.compare.Update(msg)
+		return m, compareCmd
 	}
 
 	return m, nil
 }
 
+// Determine if the pager search for the currently active pane is
+// having a query typed into it, or is displaying results at all.
+func (m Model) paneSearchState() (typing bool, searching bool) {
+	switch m.mode {
+	case ViewModeSearch:
+		if m.focus == FocusAreaPreview {
+			return m.preview.SearchTyping(), m.preview.Searching()
+		}
+	case ViewModeEvalValue:
+		return m.eval.SearchTyping(), m.eval.Searching()
+	case ViewModeHelp:
+		return m.help.SearchTyping(), m.help.Searching()
+	case ViewModeSelectScope:
+		return m.selectScope.Prompting(), m.selectScope.Prompting()
+	}
+
+	return false, false
+}
+
+func (m Model) openCopyMenu() (Model, tea.Cmd) {
+	opt := m.results.GetSelectedOption()
+	if opt == nil {
+		return m, nil
+	}
+
+	m.copyMenu = m.copyMenu.
+		SetOption(opt, m.eval.EvaluatedValue(opt.Name)).
+		SetReturnMode(m.mode)
+
+	return m, func() tea.Msg {
+		return ChangeViewModeMsg(ViewModeCopyMenu)
+	}
+}
+
+func (m Model) openCompare() (Model, tea.Cmd) {
+	opt := m.results.GetSelectedOption()
+	if opt == nil {
+		return m, nil
+	}
+
+	m.compare = m.compare.
+		SetOption(opt, m.selectScope.SelectedScope(), m.selectScope.Scopes()).
+		SetReturnMode(m.mode)
+
+	return m, func() tea.Msg {
+		return ChangeViewModeMsg(ViewModeCompare)
+	}
+}
+
 func (m Model) updateSearch(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -259,11 +444,13 @@ func (m Model) updateSearch(msg tea.Msg) (Model, tea.Cmd) {
 			return m, func() tea.Msg {
 				options, err := next.Loader()
 				return ChangeScopeMsg{
-					Name:       next.Name,
-					Options:    options,
-					Evaluator:  next.Evaluator,
-					KeepSearch: true,
-					Err:        err,
+					Name:            next.Name,
+					Options:         options,
+					Evaluator:       next.Evaluator,
+					EvaluatorOutput: next.EvaluatorOutput,
+					Definitions:     next.Definitions,
+					KeepSearch:      true,
+					Err:             err,
 				}
 			}
 
@@ -278,7 +465,7 @@ func (m Model) updateSearch(msg tea.Msg) (Model, tea.Cmd) {
 
 		case "ctrl+y":
 			if opt := m.results.GetSelectedOption(); opt != nil {
-				return m, copyToClipboardCmd(opt.Name)
+				return m, copyToClipboardCmd(m.clipboardBackends, opt.Name)
 			}
 		}
 	case RunSearchMsg:
@@ -428,6 +615,15 @@ type RunSearchMsg struct {
 	Mode  SearchMode
 }
 
+// Set the chain of clipboard backends to try, in order, when
+// copying values from any view.
+func (m Model) SetClipboardBackends(backends []clipboard.Backend) Model {
+	m.clipboardBackends = backends
+	m.eval = m.eval.SetClipboardBackends(backends)
+	m.copyMenu = m.copyMenu.SetClipboardBackends(backends)
+	return m
+}
+
 func (m Model) toggleFocus() Model {
 	switch m.focus {
 	case FocusAreaResults:
@@ -483,6 +679,10 @@ func (m Model) View() string {
 		content = marginStyle.Render(m.eval.View())
 	case ViewModeHelp:
 		content = marginStyle.Render(m.help.View())
+	case ViewModeCopyMenu:
+		content = marginStyle.Render(m.copyMenu.View())
+	case ViewModeCompare:
+		content = marginStyle.Render(m.compare.View())
 	default:
 		results := m.results.View()
 		search := m.search.View()
@@ -493,7 +693,11 @@ func (m Model) View() string {
 		content = marginStyle.Render(main)
 	}
 
-	return lipgloss.JoinVertical(lipgloss.Top, content, m.statusBar.View())
+	view := lipgloss.JoinVertical(lipgloss.Top, content, m.statusBar.View())
+
+	// Escape sequences have no width, so this does not affect the
+	// layout; the terminal sets the clipboard once it is drawn.
+	return m.clipboardSequence + view
 }
 
 type OptionTUIArgs struct {
@@ -503,6 +707,21 @@ type OptionTUIArgs struct {
 	DebounceTime      int64
 	InitialInput      string
 	LogFileName       string
+
+	// Names of clipboard backends to try in order when copying.
+	// If empty, the default backend chain is used.
+	ClipboardBackends []string
+
+	// Indentation used when expanding values to compare them,
+	// matching the formatter indent of the CLI.
+	FormatterIndent int
+
+	// Reloads configuration with ctrl+r. If this is not set, only
+	// the options of the current scope are reloaded.
+	Reload ReloadFunc
+	// Reload automatically when any of WatchFiles change.
+	Watch      bool
+	WatchFiles []string
 }
 
 func OptionTUI(args OptionTUIArgs) error {
@@ -516,7 +735,43 @@ func OptionTUI(args OptionTUIArgs) error {
 		return err
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	if len(args.ClipboardBackends) > 0 {
+		backends, err := clipboard.ParseBackends(args.ClipboardBackends)
+		if err != nil {
+			return err
+		}
+
+		*m = m.SetClipboardBackends(backends)
+	}
+
+	m.compare = m.compare.SetIndent(args.FormatterIndent)
+
+	m.reload = args.Reload
+
+	if args.Watch {
+		watcher, err := newFileWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to watch files: %w", err)
+		}
+		defer func() { _ = watcher.Close() }()
+
+		watcher.Watch(args.WatchFiles)
+		m.watcher = watcher
+	}
+
+	programOpts := []tea.ProgramOption{tea.WithAltScreen()}
+
+	// Options can be piped in through stdin, so keyboard input
+	// needs to come from the terminal itself in that case.
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		programOpts = append(programOpts, tea.WithInputTTY())
+	}
+
+	p := tea.NewProgram(m, programOpts...)
+
+	if m.watcher != nil {
+		go m.watcher.Run(p.Send)
+	}
 
 	if _, err := p.Run(); err != nil {
 		return err
@@ -1,20 +1,27 @@
 package tui
 
 import (
+	"strings"
+	"sync"
+
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/fatih/color"
 	"github.com/muesli/termenv"
+	"snare.dev/optnix/internal/clipboard"
 	"snare.dev/optnix/option"
 )
 
 type EvalValueModel struct {
 	vp      viewport.Model
 	spinner spinner.Model
+	search  PagerSearchModel
 
 	option string
+	// Attribute names of the option, from the options list
+	location []string
 
 	loading   bool
 	evaluated string
@@ -24,6 +31,18 @@ type EvalValueModel struct {
 	height int
 
 	evaluator option.EvaluatorFunc
+	output    option.EvaluatorOutput
+
+	definitionsFunc option.DefinitionsFunc
+	definitions     []option.OptionDefinition
+	definitionsErr  error
+
+	// Structured display of JSON values; only used when the
+	// evaluator output is JSON and the value could be parsed.
+	tree     JSONTreeModel
+	treeMode bool
+
+	clipboardBackends []clipboard.Backend
 }
 
 var spinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.ANSIColor(termenv.ANSIBlue))
@@ -41,17 +60,22 @@ func NewEvalValueModel(evaluator option.EvaluatorFunc) EvalValueModel {
 		vp:        vp,
 		evaluator: evaluator,
 		spinner:   sp,
+		search:    NewPagerSearchModel(),
 		loading:   false,
 	}
 }
 
 type EvalValueStartMsg struct {
-	Option string
+	Option   string
+	Location []string
 }
 
 type EvalValueFinishedMsg struct {
 	Value string
 	Err   error
+
+	Definitions    []option.OptionDefinition
+	DefinitionsErr error
 }
 
 func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
@@ -59,6 +83,30 @@ func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.treeMode && !m.loading {
+			switch msg.String() {
+			case "q", "esc":
+				return m, func() tea.Msg {
+					return ChangeViewModeMsg(ViewModeSearch)
+				}
+			}
+
+			var treeCmd tea.Cmd
+			m.tree, treeCmd = m.tree.Update(msg)
+			m = m.refreshTree()
+			return m, treeCmd
+		}
+
+		if !m.loading {
+			var searchCmd tea.Cmd
+			var handled bool
+			m.search, searchCmd, handled = m.search.HandleKey(msg)
+			if handled {
+				m = m.refreshSearch()
+				return m, searchCmd
+			}
+		}
+
 		switch msg.String() {
 		case "q", "esc":
 			return m, func() tea.Msg {
@@ -66,7 +114,7 @@ func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
 			}
 		case "ctrl+y":
 			if m.evaluated != "" && !m.loading {
-				return m, copyToClipboardCmd(m.evaluated)
+				return m, copyToClipboardCmd(m.clipboardBackends, m.evaluated)
 			}
 		}
 
@@ -75,7 +123,8 @@ func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
 		m.height = msg.Height - 4
 
 		m.vp.Width = m.width
-		m.vp.Height = m.height
+		m = m.refreshSearch()
+		m = m.refreshTree()
 
 		return m, nil
 
@@ -85,9 +134,15 @@ func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
 		}
 
 		m.option = msg.Option
+		m.location = msg.Location
 		m.loading = true
 		m.evaluated = ""
 		m.evalErr = nil
+		m.definitions = nil
+		m.definitionsErr = nil
+		m.treeMode = false
+		m.search = m.search.Clear()
+		m = m.refreshSearch()
 
 		cmds = append(cmds, m.evalOptionCmd())
 		cmds = append(cmds, m.spinner.Tick)
@@ -96,8 +151,26 @@ func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
 		m.loading = false
 		m.evaluated = msg.Value
 		m.evalErr = msg.Err
+		m.definitions = msg.Definitions
+		m.definitionsErr = msg.DefinitionsErr
+
+		m.treeMode = false
+		if m.output == option.EvaluatorOutputJSON && m.evalErr == nil && m.evaluator != nil {
+			// Fall back to displaying the raw text if the value
+			// cannot be parsed for whatever reason.
+			if tree, err := NewJSONTreeModel(m.option, m.evaluated); err == nil {
+				m.tree = tree.SetClipboardBackends(m.clipboardBackends)
+				m.treeMode = true
+			}
+		}
+
+		m.search = m.search.SetContent(m.constructValueContent())
+		m = m.refreshSearch()
+		m = m.refreshTree()
 
-		m.vp.SetContent(m.constructValueContent())
+		if m.treeMode {
+			return m, nil
+		}
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
@@ -117,12 +190,28 @@ func (m EvalValueModel) Update(msg tea.Msg) (EvalValueModel, tea.Cmd) {
 
 func (m EvalValueModel) evalOptionCmd() tea.Cmd {
 	return func() tea.Msg {
+		var result EvalValueFinishedMsg
+
+		// Definitions are usually found using a separate evaluation,
+		// so run them at the same time as the value evaluation.
+		var wg sync.WaitGroup
+		if m.definitionsFunc != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result.Definitions, result.DefinitionsErr = m.definitionsFunc(m.option, m.location)
+			}()
+		}
+
 		if m.evaluator == nil {
-			return EvalValueFinishedMsg{Value: "no evaluator is configured"}
+			result.Value = "no evaluator is configured"
+		} else {
+			result.Value, result.Err = m.evaluator(m.option, m.location)
 		}
 
-		value, err := m.evaluator(m.option)
-		return EvalValueFinishedMsg{Value: value, Err: err}
+		wg.Wait()
+
+		return result
 	}
 }
 
@@ -131,27 +220,152 @@ func (m EvalValueModel) SetEvaluator(evaluator option.EvaluatorFunc) EvalValueMo
 	return m
 }
 
-func (m EvalValueModel) SetOption(o string) (EvalValueModel, tea.Cmd) {
+// Set the function used to find where options are defined;
+// this can be nil if a scope does not support it.
+func (m EvalValueModel) SetDefinitions(definitions option.DefinitionsFunc) EvalValueModel {
+	m.definitionsFunc = definitions
+	return m
+}
+
+// Set the format that the evaluator returns values in.
+func (m EvalValueModel) SetEvaluatorOutput(output option.EvaluatorOutput) EvalValueModel {
+	m.output = output
+	return m
+}
+
+// Retrieve the evaluated value for the given option, if it has
+// finished evaluating successfully. JSON values are converted to
+// Nix expressions.
+func (m EvalValueModel) EvaluatedValue(o string) *string {
+	if m.option != o || m.loading || m.evalErr != nil || m.evaluator == nil {
+		return nil
+	}
+
+	value := m.evaluated
+	if m.output == option.EvaluatorOutputJSON {
+		if nixValue, err := option.JSONToNix(value); err == nil {
+			value = nixValue
+		}
+	}
+
+	return &value
+}
+
+func (m EvalValueModel) SetClipboardBackends(backends []clipboard.Backend) EvalValueModel {
+	m.clipboardBackends = backends
+	m.tree = m.tree.SetClipboardBackends(backends)
+	return m
+}
+
+func (m EvalValueModel) SetOption(o string, location []string) (EvalValueModel, tea.Cmd) {
 	if o == m.option {
 		return m, nil
 	}
 
 	m.option = o
+	m.location = location
 	m.loading = true
 	m.evaluated = ""
 	m.evalErr = nil
+	m.definitions = nil
+	m.definitionsErr = nil
+	m.treeMode = false
+	m.search = m.search.Clear()
+	m = m.refreshSearch()
 
 	return m, m.evalOptionCmd()
 }
 
+// Evaluate the current option again, such as after its scope has
+// been reloaded.
+func (m EvalValueModel) Reevaluate() (EvalValueModel, tea.Cmd) {
+	o := m.option
+	if o == "" {
+		return m, nil
+	}
+
+	m.option = ""
+	return m.SetOption(o, m.location)
+}
+
+// Forget the evaluated value, so that the option is evaluated again
+// the next time it is shown.
+func (m EvalValueModel) ClearValue() EvalValueModel {
+	m.option = ""
+	m.evaluated = ""
+	return m
+}
+
+// Re-render the tree display of a JSON value, if it is active.
+func (m EvalValueModel) refreshTree() EvalValueModel {
+	if !m.treeMode || m.loading {
+		return m
+	}
+
+	width := m.vp.Width - m.vp.Style.GetHorizontalFrameSize()
+	height := m.vp.Height - m.vp.Style.GetVerticalFrameSize()
+
+	// Definitions are shown below the tree, but they should
+	// never take up more than half of the available space.
+	definitions := m.constructDefinitionsContent()
+	if definitions != "" {
+		definitions = lipgloss.NewStyle().MaxHeight(height / 2).Render(definitions)
+		height -= lipgloss.Height(definitions) + 1
+	}
+
+	m.tree = m.tree.SetSize(width, height)
+
+	// The tree handles its own scrolling, and is always
+	// sized to fit inside of the viewport.
+	content := m.tree.View()
+	if definitions != "" {
+		content += "\n\n" + definitions
+	}
+
+	m.vp.SetContent(content)
+	m.vp.GotoTop()
+
+	return m
+}
+
+// Re-render the value content with any search matches highlighted,
+// and scroll to the current match.
+func (m EvalValueModel) refreshSearch() EvalValueModel {
+	m.vp.Height = m.height
+	if m.search.Visible() {
+		// Leave room for the search bar.
+		m.vp.Height--
+	}
+
+	if m.loading || m.treeMode {
+		return m
+	}
+
+	m.vp.SetContent(m.search.Render())
+	scrollToLine(&m.vp, m.search.CurrentLine())
+
+	return m
+}
+
+// Whether or not the value search bar is currently visible.
+func (m EvalValueModel) Searching() bool {
+	return m.search.Visible()
+}
+
+// Whether or not a search query is currently being typed.
+func (m EvalValueModel) SearchTyping() bool {
+	return m.search.Typing()
+}
+
 func (m EvalValueModel) View() string {
+	if m.search.Visible() {
+		return lipgloss.JoinVertical(lipgloss.Left, m.vp.View(), m.search.View(m.width))
+	}
+
 	return m.vp.View()
 }
 
-var (
-	evalSuccessColor = color.New(color.FgWhite)
-	evalErrorColor   = color.New(color.FgRed).Add(color.Bold)
-)
+var evalErrorColor = color.New(color.FgRed).Add(color.Bold)
 
 func (m EvalValueModel) constructLoadingContent() string {
 	title := lipgloss.PlaceHorizontal(m.width, lipgloss.Left, titleStyle.Render(m.option))
@@ -176,8 +390,26 @@ func (m EvalValueModel) constructValueContent() string {
 
 		body = evalErrorColor.Sprint(errStr)
 	} else {
-		body = evalSuccessColor.Sprint(m.evaluated)
+		body = option.HighlightNix(m.evaluated)
+	}
+
+	if definitions := m.constructDefinitionsContent(); definitions != "" {
+		body += "\n\n" + definitions
 	}
 
 	return title + "\n" + line + "\n" + body
 }
+
+func (m EvalValueModel) constructDefinitionsContent() string {
+	if m.definitionsErr != nil {
+		return titleStyle.Render("Defined In") + "\n" +
+			evalErrorColor.Sprint(option.DefinitionsErrorText(m.definitionsErr))
+	}
+
+	if len(m.definitions) == 0 {
+		return ""
+	}
+
+	return titleStyle.Render("Defined In") + "\n" +
+		strings.TrimRight(option.FormatDefinitions(m.definitions), "\n")
+}
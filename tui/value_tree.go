@@ -0,0 +1,423 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"snare.dev/optnix/internal/clipboard"
+	"snare.dev/optnix/option"
+)
+
+var (
+	treeCursorStyle = lipgloss.NewStyle().
+			Background(lipgloss.ANSIColor(termenv.ANSIBlue)).
+			Foreground(lipgloss.ANSIColor(termenv.ANSIBrightWhite))
+	treeKeyStyle     = lipgloss.NewStyle().Foreground(ansiBlue)
+	treeSummaryStyle = lipgloss.NewStyle().Foreground(lipgloss.ANSIColor(termenv.ANSIBrightBlack)).Italic(true)
+	treePathStyle    = lipgloss.NewStyle().Foreground(ansiCyan)
+)
+
+type jsonNodeKind int
+
+const (
+	jsonNodeScalar jsonNodeKind = iota
+	jsonNodeObject
+	jsonNodeArray
+)
+
+type jsonNode struct {
+	kind jsonNodeKind
+
+	// Attribute name or list index of this node in its parent
+	key   string
+	index int
+	// Whether or not this node is an element of a list
+	inList bool
+
+	// Raw JSON text for scalar values
+	scalar string
+
+	parent   *jsonNode
+	children []*jsonNode
+	expanded bool
+	depth    int
+}
+
+// Parse a JSON document into a tree, preserving the order of keys.
+func parseJSONTree(value string) (*jsonNode, error) {
+	d := json.NewDecoder(strings.NewReader(value))
+	d.UseNumber()
+
+	root, err := decodeJSONNode(d, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected trailing data after JSON value")
+	}
+
+	root.expanded = true
+
+	return root, nil
+}
+
+func decodeJSONNode(d *json.Decoder, parent *jsonNode, depth int) (*jsonNode, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &jsonNode{parent: parent, depth: depth}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			node.kind = jsonNodeObject
+			for d.More() {
+				keyTok, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				child, err := decodeJSONNode(d, node, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				child.key = keyTok.(string)
+				node.children = append(node.children, child)
+			}
+		case '[':
+			node.kind = jsonNodeArray
+			for i := 0; d.More(); i++ {
+				child, err := decodeJSONNode(d, node, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				child.index = i
+				child.inList = true
+				node.children = append(node.children, child)
+			}
+		}
+
+		// Consume the closing delimiter.
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+	default:
+		node.kind = jsonNodeScalar
+		encoded, err := json.Marshal(tok)
+		if err != nil {
+			return nil, err
+		}
+		node.scalar = string(encoded)
+	}
+
+	return node, nil
+}
+
+// Reconstruct the JSON text of this node and its children.
+func (n *jsonNode) JSON() string {
+	var buf bytes.Buffer
+	n.writeJSON(&buf)
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		return buf.String()
+	}
+
+	return indented.String()
+}
+
+func (n *jsonNode) writeJSON(buf *bytes.Buffer) {
+	switch n.kind {
+	case jsonNodeScalar:
+		buf.WriteString(n.scalar)
+	case jsonNodeObject:
+		buf.WriteByte('{')
+		for i, c := range n.children {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(c.key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			c.writeJSON(buf)
+		}
+		buf.WriteByte('}')
+	case jsonNodeArray:
+		buf.WriteByte('[')
+		for i, c := range n.children {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			c.writeJSON(buf)
+		}
+		buf.WriteByte(']')
+	}
+}
+
+// Construct the Nix attribute path of this node, relative to the
+// root option. List elements are displayed with an index suffix.
+func (n *jsonNode) Path(root string) string {
+	var segments []*jsonNode
+	for c := n; c.parent != nil; c = c.parent {
+		segments = append(segments, c)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(root)
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		s := segments[i]
+		if s.inList {
+			fmt.Fprintf(&sb, "[%d]", s.index)
+		} else {
+			sb.WriteString("." + option.FormatAttrPath([]string{s.key}))
+		}
+	}
+
+	return sb.String()
+}
+
+func (n *jsonNode) isContainer() bool {
+	return n.kind != jsonNodeScalar
+}
+
+func (n *jsonNode) setExpandedRecursive(expanded bool) {
+	if !n.isContainer() {
+		return
+	}
+
+	n.expanded = expanded
+	for _, c := range n.children {
+		c.setExpandedRecursive(expanded)
+	}
+}
+
+func (n *jsonNode) summary() string {
+	switch n.kind {
+	case jsonNodeObject:
+		if len(n.children) == 1 {
+			return "{ 1 attribute }"
+		}
+		return fmt.Sprintf("{ %d attributes }", len(n.children))
+	case jsonNodeArray:
+		if len(n.children) == 1 {
+			return "[ 1 element ]"
+		}
+		return fmt.Sprintf("[ %d elements ]", len(n.children))
+	}
+	return n.scalar
+}
+
+// An explorer for JSON values that displays them as a
+// collapsible tree.
+type JSONTreeModel struct {
+	root   *jsonNode
+	option string
+
+	rows   []*jsonNode
+	cursor int
+	offset int
+
+	clipboardBackends []clipboard.Backend
+
+	width  int
+	height int
+}
+
+func NewJSONTreeModel(optionName string, value string) (JSONTreeModel, error) {
+	root, err := parseJSONTree(value)
+	if err != nil {
+		return JSONTreeModel{}, err
+	}
+
+	m := JSONTreeModel{
+		root:   root,
+		option: optionName,
+	}
+	m.rows = m.visibleRows()
+
+	return m, nil
+}
+
+func (m JSONTreeModel) SetSize(width, height int) JSONTreeModel {
+	m.width = width
+	m.height = height
+	m = m.clampOffset()
+	return m
+}
+
+func (m JSONTreeModel) SetClipboardBackends(backends []clipboard.Backend) JSONTreeModel {
+	m.clipboardBackends = backends
+	return m
+}
+
+func (m JSONTreeModel) visibleRows() []*jsonNode {
+	var rows []*jsonNode
+
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		rows = append(rows, n)
+		if n.isContainer() && n.expanded {
+			for _, c := range n.children {
+				walk(c)
+			}
+		}
+	}
+	walk(m.root)
+
+	return rows
+}
+
+func (m JSONTreeModel) Update(msg tea.Msg) (JSONTreeModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || len(m.rows) == 0 {
+		return m, nil
+	}
+
+	current := m.rows[m.cursor]
+
+	switch keyMsg.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, len(m.rows)-1)
+	case "pgup", "b":
+		m.cursor = max(m.cursor-m.bodyHeight(), 0)
+	case "pgdown", "f", " ":
+		m.cursor = min(m.cursor+m.bodyHeight(), len(m.rows)-1)
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.rows) - 1
+
+	case "enter":
+		if current.isContainer() {
+			current.expanded = !current.expanded
+		}
+	case "right", "l":
+		if current.isContainer() {
+			current.expanded = true
+		}
+	case "left", "h":
+		if current.isContainer() && current.expanded {
+			current.expanded = false
+		} else if current.parent != nil {
+			m.cursor = m.rowIndex(current.parent)
+		}
+	case "L":
+		current.setExpandedRecursive(true)
+	case "H":
+		current.setExpandedRecursive(false)
+		// Keep the root expanded, otherwise there is nothing to show.
+		m.root.expanded = true
+
+	case "ctrl+y":
+		return m, copyToClipboardCmd(m.clipboardBackends, current.JSON())
+	case "p":
+		return m, copyToClipboardCmd(m.clipboardBackends, current.Path(m.option))
+	}
+
+	m.rows = m.visibleRows()
+	m.cursor = min(m.cursor, len(m.rows)-1)
+	m = m.clampOffset()
+
+	return m, nil
+}
+
+func (m JSONTreeModel) rowIndex(n *jsonNode) int {
+	for i, r := range m.rows {
+		if r == n {
+			return i
+		}
+	}
+	return 0
+}
+
+// Number of rows available for displaying the tree itself,
+// excluding the path display and help line.
+func (m JSONTreeModel) bodyHeight() int {
+	return max(m.height-2, 1)
+}
+
+func (m JSONTreeModel) clampOffset() JSONTreeModel {
+	height := m.bodyHeight()
+
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+height {
+		m.offset = m.cursor - height + 1
+	}
+
+	m.offset = max(min(m.offset, len(m.rows)-height), 0)
+
+	return m
+}
+
+func (m JSONTreeModel) View() string {
+	lines := make([]string, 0, m.height)
+
+	path := "(none)"
+	if len(m.rows) > 0 {
+		path = m.rows[m.cursor].Path(m.option)
+	}
+	lines = append(lines, truncateString(treePathStyle.Render(path), m.width))
+
+	end := min(m.offset+m.bodyHeight(), len(m.rows))
+	for i := m.offset; i < end; i++ {
+		lines = append(lines, m.renderRow(m.rows[i], i == m.cursor))
+	}
+
+	for len(lines) < m.height-1 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, hintStyle.Render("h/l: collapse/expand, ctrl+y: copy subtree, p: copy path"))
+
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(strings.Join(lines, "\n"))
+}
+
+func (m JSONTreeModel) renderRow(n *jsonNode, selected bool) string {
+	indent := strings.Repeat("  ", n.depth)
+
+	marker := "  "
+	if n.isContainer() {
+		if n.expanded {
+			marker = "▾ "
+		} else {
+			marker = "▸ "
+		}
+	}
+
+	var label string
+	switch {
+	case n.parent == nil:
+		label = m.option
+	case n.inList:
+		label = fmt.Sprintf("[%d]", n.index)
+	default:
+		label = option.FormatAttrPath([]string{n.key})
+	}
+
+	var value string
+	if !n.isContainer() {
+		value = " = " + option.HighlightNix(n.scalar)
+	} else if !n.expanded {
+		value = " " + treeSummaryStyle.Render(n.summary())
+	}
+
+	if selected {
+		return treeCursorStyle.Render(indent + marker + label)
+	}
+
+	return indent + marker + treeKeyStyle.Render(label) + value
+}
@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watches a set of files for changes, which can be replaced at any
+// time (i.e. after configuration has been reloaded).
+//
+// Directories are watched instead of the files themselves, since
+// editors and tools such as `nix build` often replace files instead
+// of writing to them, which would stop a watch on the file itself.
+//
+// Watches follow symlinks, so symlinks in the path to a file (such
+// as `result` or `/etc/static`) are watched too, since replacing
+// them does not change anything in the directory they point to.
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+
+	mu    sync.Mutex
+	files map[string]bool
+	// Watched directories, along with the directory they resolved
+	// to when the watch was added
+	dirs map[string]string
+}
+
+func newFileWatcher() (*fileWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileWatcher{
+		watcher: w,
+		files:   make(map[string]bool),
+		dirs:    make(map[string]string),
+	}, nil
+}
+
+// Replace the set of watched files.
+func (w *fileWatcher) Watch(files []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.files = make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+
+	for _, f := range files {
+		if abs, err := filepath.Abs(f); err == nil {
+			f = abs
+		}
+
+		for _, path := range append([]string{f}, symlinksInPath(f)...) {
+			w.files[path] = true
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+
+	for dir, resolved := range w.dirs {
+		// A watch stays on the directory that a symlink pointed to
+		// when it was added, so it needs to be added again once the
+		// symlink points somewhere else.
+		if !dirs[dir] || resolveDir(dir) != resolved {
+			_ = w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+
+	for dir := range dirs {
+		if _, ok := w.dirs[dir]; ok {
+			continue
+		}
+
+		// Directories that do not exist (yet) cannot be watched.
+		if err := w.watcher.Add(dir); err == nil {
+			w.dirs[dir] = resolveDir(dir)
+		}
+	}
+}
+
+func resolveDir(dir string) string {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		return resolved
+	}
+	return dir
+}
+
+// Maximum number of symlinks to follow in a path, to avoid looping
+// forever on symlinks that point to themselves.
+const maxSymlinks = 255
+
+// Find every symlink that is followed when resolving an absolute
+// path, as paths without symlinks in their parent directories.
+func symlinksInPath(path string) []string {
+	var links []string
+
+	resolved := string(filepath.Separator)
+	rest := splitPath(path)
+
+	for len(rest) > 0 && len(links) < maxSymlinks {
+		next := filepath.Join(resolved, rest[0])
+		rest = rest[1:]
+
+		info, err := os.Lstat(next)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		links = append(links, next)
+
+		target, err := os.Readlink(next)
+		if err != nil {
+			break
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+
+		// The target can have symlinks of its own, so resolve it
+		// again from the start.
+		rest = append(splitPath(target), rest...)
+		resolved = string(filepath.Separator)
+	}
+
+	return links
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.Clean(path), string(filepath.Separator)) {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func (w *fileWatcher) isWatched(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.files[path]
+}
+
+// Send a FileChangedMsg for every change to a watched file, until
+// the watcher is closed.
+func (w *fileWatcher) Run(send func(tea.Msg)) {
+	for event := range w.watcher.Events {
+		if event.Has(fsnotify.Chmod) || !w.isWatched(event.Name) {
+			continue
+		}
+
+		send(FileChangedMsg{Path: event.Name})
+	}
+}
+
+func (w *fileWatcher) Close() error {
+	return w.watcher.Close()
+}